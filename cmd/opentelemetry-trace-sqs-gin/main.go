@@ -3,22 +3,29 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/udhos/opentelemetry-trace-sqs/internal/backend"
 	"github.com/udhos/opentelemetry-trace-sqs/internal/config"
 	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/sqslistener"
 	"github.com/udhos/otelconfig/oteltrace"
 )
 
@@ -29,6 +36,7 @@ type application struct {
 	tracer      trace.Tracer
 	queueInput  backend.SqsQueue
 	queueOutput backend.SqsQueue
+	carrier     *otelsqs.SqsCarrierAttributes
 }
 
 type serverGin struct {
@@ -53,6 +61,9 @@ func main() {
 
 	debug := os.Getenv("DEBUG") == "true"
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	//
 	// initialize tracing
 	//
@@ -75,6 +86,14 @@ func main() {
 		app.tracer = tr
 	}
 
+	//
+	// SQS propagator defaults to B3, unless OTEL_PROPAGATORS is defined
+	//
+
+	if errProp := otelsqs.SetTextMapPropagatorFromEnv(); errProp != nil {
+		log.Fatalf("propagator: %v", errProp)
+	}
+
 	//
 	// initialize http
 	//
@@ -90,6 +109,8 @@ func main() {
 
 	app.queueInput = backend.NewSqsClient("input sqs queue", app.config.QueueURLInput, app.config.QueueRoleARNInput, app.me, app.config.EndpointURL)
 	app.queueOutput = backend.NewSqsClient("output sqs queue", app.config.QueueURLOutput, app.config.QueueRoleARNOutput, app.me, app.config.EndpointURL)
+	app.carrier = otelsqs.NewCarrier(otelsqs.WithMeterProvider(otel.GetMeterProvider()))
+	app.queueOutput.Sender = backend.NewSqsSender(app.queueOutput, app.carrier, app.config.SendLingerOutput, debug)
 
 	//
 	// start http server
@@ -98,24 +119,93 @@ func main() {
 	go func() {
 		log.Printf("application server: listening on %s", app.config.HTTPAddr)
 		err := app.server.server.ListenAndServe()
-		log.Fatalf("application server: exited: %v", err)
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("application server: exited: %v", err)
+		}
+	}()
+
+	//
+	// start sqs sender, stopped only after the listener and the server
+	//
+
+	ctxSender, cancelSender := context.WithCancel(context.Background())
+	defer cancelSender()
+
+	senderDone := make(chan struct{})
+
+	go func() {
+		app.queueOutput.Sender.Run(ctxSender)
+		close(senderDone)
 	}()
 
 	//
 	// start sqs
 	//
 
+	extractPolicy, errPolicy := otelsqs.ParseExtractPolicy(app.config.ExtractPolicyInput)
+	if errPolicy != nil {
+		log.Fatalf("input queue: %v", errPolicy)
+	}
+
 	sqsApp := &backend.SqsApplication{
-		QueueInput:  app.queueInput,
-		QueueOutput: app.queueOutput,
-		Tracer:      app.tracer,
-		BackendURL:  app.config.BackendURL,
-		Debug:       debug,
+		QueueInput:        app.queueInput,
+		QueueOutput:       app.queueOutput,
+		Tracer:            app.tracer,
+		Carrier:           app.carrier,
+		BackendURL:        app.config.BackendURL,
+		Debug:             debug,
+		ExtractPolicy:     extractPolicy,
+		BodyCapture:       app.config.BodyCaptureInput,
+		BodyRedact:        strings.FieldsFunc(app.config.BodyRedactInput, func(r rune) bool { return r == ',' }),
+		Receivers:         app.config.ReceiversInput,
+		Workers:           app.config.WorkersInput,
+		VisibilityTimeout: app.config.VisibilityInput,
+		NackDelay:         app.config.NackDelayInput,
+		Backoff: sqslistener.Backoff{
+			Initial:    app.config.BackoffInitial,
+			Max:        app.config.BackoffMax,
+			Multiplier: app.config.BackoffMultiplier,
+			Jitter:     app.config.BackoffJitter,
+		},
 	}
 
-	go backend.SqsListener(sqsApp)
+	listenerDone := make(chan struct{})
 
-	<-make(chan struct{}) // wait forever
+	go func() {
+		backend.SqsListener(ctx, sqsApp)
+		close(listenerDone)
+	}()
+
+	//
+	// wait for termination signal, then shut down
+	//
+
+	<-ctx.Done()
+
+	log.Printf("shutting down: %v", context.Cause(ctx))
+
+	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancelShutdown()
+
+	if errShutdown := app.server.server.Shutdown(ctxShutdown); errShutdown != nil {
+		log.Printf("application server: shutdown: %v", errShutdown)
+	}
+
+	select {
+	case <-listenerDone:
+	case <-ctxShutdown.Done():
+		log.Printf("sqs listener: shutdown: %v", ctxShutdown.Err())
+	}
+
+	cancelSender()
+
+	select {
+	case <-senderDone:
+	case <-ctxShutdown.Done():
+		log.Printf("sqs sender: shutdown: %v", ctxShutdown.Err())
+	}
+
+	// deferred tracer cancel flushes spans
 }
 
 func handlerRoute(c *gin.Context, app *application) {
@@ -138,15 +228,11 @@ func handlerRoute(c *gin.Context, app *application) {
 		MessageAttributes: make(map[string]types.MessageAttributeValue),
 	}
 
-	if errInject := otelsqs.NewCarrier().Inject(ctx, msg.MessageAttributes); errInject != nil {
-		log.Fatalf("inject error: %v", errInject)
-	}
-
 	//
 	// send to SQS
 	//
 
-	backend.SqsSend(ctx, app.tracer, app.queueOutput, msg)
+	backend.SqsSend(ctx, app.tracer, app.carrier, app.queueOutput, msg)
 
 	//
 	// send to HTTP
@@ -0,0 +1,68 @@
+// Package main implements a local SQS stand-in for demos and integration tests.
+//
+// It serves the SQS JSON protocol over HTTP from memory, persisting nothing.
+// Point clients at it with ENDPOINT_URL.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/udhos/opentelemetry-trace-sqs/internal/env"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+)
+
+func main() {
+
+	me := filepath.Base(os.Args[0])
+
+	addr := env.String("HTTP_ADDR", ":9324")
+	queues := env.String("QUEUES", "")
+	debug := env.Bool("DEBUG", false)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fake := sqstest.New()
+
+	for _, name := range strings.Split(queues, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		fake.CreateQueue(name)
+		log.Printf("%s: created queue: %s", me, sqstest.QueueURL(name))
+	}
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: sqstest.NewHandler(fake, debug),
+	}
+
+	go func() {
+		log.Printf("%s: serving SQS on %s", me, addr)
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("%s: listen: %v", me, err)
+		}
+	}()
+
+	<-ctx.Done()
+
+	log.Printf("%s: shutting down", me)
+
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctxShutdown); err != nil {
+		log.Printf("%s: shutdown: %v", me, err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package attrvalid checks message attributes against the rules shared by SQS and SNS.
+//
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-message-metadata.html
+// https://docs.aws.amazon.com/sns/latest/dg/sns-message-attributes.html
+package attrvalid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxNameLength is the limit for message attribute names.
+const MaxNameLength = 256
+
+// Check checks a message attribute given its name, data type and value.
+func Check(name string, dataType, stringValue *string, binaryValue []byte) error {
+	if errName := CheckName(name); errName != nil {
+		return errName
+	}
+	if dataType == nil {
+		return fmt.Errorf("%s: missing data type", name)
+	}
+	if stringValue != nil && *stringValue == "" {
+		return fmt.Errorf("%s: empty value", name)
+	}
+	if stringValue == nil && len(binaryValue) == 0 {
+		return fmt.Errorf("%s: empty value", name)
+	}
+	return nil
+}
+
+// CheckName checks a message attribute name.
+func CheckName(name string) error {
+	if name == "" || len(name) > MaxNameLength {
+		return fmt.Errorf("name length: %d", len(name))
+	}
+	for _, r := range name {
+		if !validNameRune(r) {
+			return fmt.Errorf("%s: bad character: %q", name, r)
+		}
+	}
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return fmt.Errorf("%s: misplaced period", name)
+	}
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "aws.") || strings.HasPrefix(lower, "amazon.") {
+		return fmt.Errorf("%s: reserved prefix", name)
+	}
+	return nil
+}
+
+func validNameRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r == '-', r == '_', r == '.':
+		return true
+	}
+	return false
+}
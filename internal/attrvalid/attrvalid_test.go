@@ -0,0 +1,46 @@
+package attrvalid
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCheckName(t *testing.T) {
+	valid := []string{"traceparent", "X-Amzn-Trace-Id", "otel.trace_id", "a.b-c_d"}
+	invalid := []string{"", ".a", "a.", "a..b", "aws.x", "Amazon.x", "a b", "a/b", string(make([]byte, 257))}
+
+	for _, name := range valid {
+		if err := CheckName(name); err != nil {
+			t.Errorf("%q: unexpected error: %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if err := CheckName(name); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		dataType    *string
+		stringValue *string
+		binaryValue []byte
+		valid       bool
+	}{
+		{"string", aws.String("String"), aws.String("x"), nil, true},
+		{"binary", aws.String("Binary"), nil, []byte{1}, true},
+		{"missing data type", nil, aws.String("x"), nil, false},
+		{"empty string", aws.String("String"), aws.String(""), nil, false},
+		{"empty binary", aws.String("Binary"), nil, nil, false},
+	}
+
+	for _, data := range testCases {
+		err := Check("key", data.dataType, data.stringValue, data.binaryValue)
+		if data.valid != (err == nil) {
+			t.Errorf("%s: valid=%t got error: %v", data.name, data.valid, err)
+		}
+	}
+}
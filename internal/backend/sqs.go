@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,14 +15,28 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/udhos/boilerplate/awsconfig"
 	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/sqslistener"
+	"github.com/udhos/opentelemetry-trace-sqs/sqssender"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// SqsClient is the subset of the SQS API used by the applications:
+// receiving with sqslistener, batch sending with sqssender, and single
+// sends with SqsSend.
+type SqsClient interface {
+	sqslistener.Client
+	sqssender.Client
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
 // SqsQueue holds sqs client.
 type SqsQueue struct {
-	SqsClient *sqs.Client
+	SqsClient SqsClient
 	URL       string
+	Sender    *sqssender.Sender // batches messages sent with SqsSend, if set
 }
 
 // NewSqsClient creates sqs client.
@@ -53,6 +69,20 @@ func NewSqsClient(caller, queueURL, roleArn, roleSessionName, endpointURL string
 	return q
 }
 
+// NewSqsSender creates a sender batching messages for queue,
+// recording metrics with carrier.
+// The caller must run the sender, see sqssender.Sender.Run.
+func NewSqsSender(queue SqsQueue, carrier *otelsqs.SqsCarrierAttributes, linger time.Duration,
+	debug bool) *sqssender.Sender {
+	return &sqssender.Sender{
+		Client:   queue.SqsClient,
+		QueueURL: queue.URL,
+		Carrier:  carrier,
+		Linger:   linger,
+		Debug:    debug,
+	}
+}
+
 func getRegion(queueURL string) (string, error) {
 	fields := strings.SplitN(queueURL, ".", 3)
 	if len(fields) < 3 {
@@ -65,113 +95,76 @@ func getRegion(queueURL string) (string, error) {
 
 // SqsApplication holds sqs application.
 type SqsApplication struct {
-	QueueInput  SqsQueue
-	QueueOutput SqsQueue
-	Tracer      trace.Tracer
-	BackendURL  string
-	Debug       bool
+	QueueInput        SqsQueue
+	QueueOutput       SqsQueue
+	Tracer            trace.Tracer
+	Carrier           *otelsqs.SqsCarrierAttributes // injects trace context and records metrics for QueueOutput
+	BackendURL        string
+	Debug             bool
+	Handler           sqslistener.Handler   // handles input queue messages, defaults to forwarding with ForwardHandler
+	ExtractPolicy     otelsqs.ExtractPolicy // how trace context from input queue messages is used
+	BodyCapture       bool                  // record input queue message bodies on spans
+	BodyRedact        []string              // JSON field paths redacted from captured bodies
+	Receivers         int                   // goroutines receiving from input queue, defaults to 1
+	Workers           int                   // goroutines handling messages, defaults to 1
+	VisibilityTimeout time.Duration         // extended while messages are handled, zero or under 2s disables
+	NackDelay         time.Duration         // visibility delay for failed messages, negative leaves them for redelivery
+	Backoff           sqslistener.Backoff   // delays for retrying failed receive and delete calls
 }
 
-// SqsListener runs sqs application.
-func SqsListener(app *SqsApplication) {
-
-	const me = "SqsListener"
-
-	q := app.QueueInput
-
-	debug := app.Debug
-
-	const cooldown = 10 * time.Second
+// SqsListener runs sqs application until ctx is cancelled.
+// See sqslistener.Listener.Run.
+func SqsListener(ctx context.Context, app *SqsApplication) {
 
-	input := &sqs.ReceiveMessageInput{
-		QueueUrl: aws.String(q.URL),
-		AttributeNames: []types.QueueAttributeName{
-			"SentTimestamp",
-		},
-		MaxNumberOfMessages: 10, // 1..10
-		MessageAttributeNames: []string{
-			"All",
-		},
-		WaitTimeSeconds: 20, // 0..20
+	options := []otelsqs.Option{
+		otelsqs.WithQueueTimeSpan(true),
+		otelsqs.WithMeterProvider(otel.GetMeterProvider()),
+		otelsqs.WithExtractPolicy(app.ExtractPolicy),
 	}
 
-	carrier := otelsqs.NewCarrier()
-
-	for {
-		if debug {
-			log.Printf("%s: ready: %s", me, q.URL)
-		}
-
-		//
-		// read message from sqs queue
-		//
-
-		//m.receive.WithLabelValues(queueID).Inc()
-
-		resp, errRecv := q.SqsClient.ReceiveMessage(context.TODO(), input)
-		if errRecv != nil {
-			log.Printf("%s: sqs.ReceiveMessage: error: %v, sleeping %v",
-				me, errRecv, cooldown)
-			time.Sleep(cooldown)
-			continue
-		}
-
-		//
-		// push messages into channel
-		//
-
-		count := len(resp.Messages)
-
-		if debug {
-			log.Printf("%s: sqs.ReceiveMessage: found %d messages", me, count)
-		}
+	if app.BodyCapture {
+		options = append(options, otelsqs.WithBodyCapture(otelsqs.BodyCapture{
+			RedactFields:   app.BodyRedact,
+			RedactPatterns: []*regexp.Regexp{otelsqs.RedactEmail, otelsqs.RedactCardNumber},
+		}))
+	}
 
-		if count == 0 {
-			if debug {
-				log.Printf("%s: empty receive, sleeping %v",
-					me, cooldown)
-			}
-			// this cooldown prevents us from hammering the api on empty receives.
-			// it shouldn't really on live aws api, but it does take place on
-			// simulated apis.
-			time.Sleep(cooldown)
-			continue
-		}
+	handler := app.Handler
+	if handler == nil {
+		handler = &ForwardHandler{App: app}
+	}
 
-		for i, msg := range resp.Messages {
-			if debug {
-				log.Printf("%s: %d/%d MessageId: %s", me, i+1, count, *msg.MessageId)
-			}
-
-			sqsHandle(app, carrier, msg)
-
-			//
-			// delete from source queue
-			//
-
-			inputDelete := &sqs.DeleteMessageInput{
-				QueueUrl:      aws.String(q.URL),
-				ReceiptHandle: msg.ReceiptHandle,
-			}
-			_, errDelete := q.SqsClient.DeleteMessage(context.TODO(), inputDelete)
-			if errDelete != nil {
-				log.Printf("%s: MessageId: %s - sqs.DeleteMessage: error: %v, sleeping %v",
-					me, *msg.MessageId, errDelete, cooldown)
-				time.Sleep(cooldown)
-			}
-		}
+	listener := &sqslistener.Listener{
+		Client:            app.QueueInput.SqsClient,
+		QueueURL:          app.QueueInput.URL,
+		Handler:           handler,
+		Carrier:           otelsqs.NewCarrier(options...),
+		Receivers:         app.Receivers,
+		Workers:           app.Workers,
+		VisibilityTimeout: app.VisibilityTimeout,
+		NackDelay:         max(app.NackDelay, 0),
+		Backoff:           app.Backoff,
+		Debug:             app.Debug,
 	}
 
+	listener.Run(ctx)
 }
 
-// sqsHandle forwards SQS message to both SQS and HTTP.
-// will retrieve traceID from sqsMessage,
+// ForwardHandler forwards SQS message to both SQS and HTTP.
+type ForwardHandler struct {
+	App *SqsApplication
+}
+
+// Handle forwards sqsMessage to both SQS and HTTP.
+// will retrieve traceID from ctx,
 // and create a context with traceID for HTTP.
-func sqsHandle(app *SqsApplication, carrier *otelsqs.SqsCarrierAttributes, sqsMessage types.Message) {
+// returns ResultAck on success, otherwise ResultNack if a NACK delay is
+// configured, or ResultRetry.
+func (h *ForwardHandler) Handle(ctx context.Context, sqsMessage types.Message) sqslistener.Result {
 
 	const me = "sqsHandle"
 
-	ctx := carrier.Extract(context.Background(), sqsMessage.MessageAttributes)
+	app := h.App
 
 	ctxNew, span := app.Tracer.Start(ctx, me)
 	defer span.End()
@@ -181,7 +174,7 @@ func sqsHandle(app *SqsApplication, carrier *otelsqs.SqsCarrierAttributes, sqsMe
 	//
 	// send to SQS
 	//
-	SqsSend(ctx, app.Tracer, app.QueueOutput, sqsMessage)
+	errSend := SqsSend(ctx, app.Tracer, app.Carrier, app.QueueOutput, sqsMessage)
 
 	//
 	// send to HTTP
@@ -192,11 +185,25 @@ func sqsHandle(app *SqsApplication, carrier *otelsqs.SqsCarrierAttributes, sqsMe
 		log.Print(m)
 		span.SetStatus(codes.Error, m)
 	}
+
+	if errSend == nil && errHTTP == nil {
+		return sqslistener.ResultAck
+	}
+
+	if app.NackDelay < 0 {
+		return sqslistener.ResultRetry
+	}
+
+	return sqslistener.ResultNack
 }
 
 // SqsSend only submits message to SQS.
-// attribute with traceID must have been set in sqsMessage.
-func SqsSend(ctx context.Context, tracer trace.Tracer, queue SqsQueue, sqsMessage types.Message) {
+// If queue.Sender is set, the message is batched with SendMessageBatch
+// under its own producer span, using the sender carrier.
+// Otherwise, carrier injects the trace context and records the send;
+// nil carrier uses a default carrier, which records no metrics.
+func SqsSend(ctx context.Context, tracer trace.Tracer, carrier *otelsqs.SqsCarrierAttributes,
+	queue SqsQueue, sqsMessage types.Message) error {
 
 	const me = "SqsSend"
 
@@ -206,15 +213,32 @@ func SqsSend(ctx context.Context, tracer trace.Tracer, queue SqsQueue, sqsMessag
 	input := &sqs.SendMessageInput{
 		QueueUrl:          aws.String(queue.URL),
 		DelaySeconds:      0, // 0..900
-		MessageAttributes: sqsMessage.MessageAttributes,
+		MessageAttributes: maps.Clone(sqsMessage.MessageAttributes),
 		MessageBody:       sqsMessage.Body,
 	}
 
-	_, errSend := queue.SqsClient.SendMessage(newCtx, input)
+	var errSend error
+
+	if queue.Sender != nil {
+		_, errSend = queue.Sender.Send(newCtx, input)
+	} else {
+		if carrier == nil {
+			carrier = otelsqs.NewCarrier()
+		}
+		if errInject := carrier.InjectInput(newCtx, input); errInject != nil {
+			log.Printf("%s: MessageId: %s - inject: error: %v",
+				me, aws.ToString(sqsMessage.MessageId), errInject)
+		}
+		_, errSend = queue.SqsClient.SendMessage(newCtx, input)
+		carrier.RecordSent(newCtx, queue.URL, 1, errSend)
+	}
+
 	if errSend != nil {
 		m := fmt.Sprintf("%s: MessageId: %s - SendMessage: error: %v",
 			me, aws.ToString(sqsMessage.MessageId), errSend)
 		log.Print(m)
 		span.SetStatus(codes.Error, m)
 	}
+
+	return errSend
 }
@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	queueInput  = "https://sqs.us-east-1.amazonaws.com/123456789012/input"
+	queueOutput = "https://sqs.us-east-1.amazonaws.com/123456789012/output"
+)
+
+func TestSqsListenerForward(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	tracer := provider.Tracer("test")
+
+	var backendBody atomic.Value
+
+	backend := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		backendBody.Store(string(body))
+	}))
+	defer backend.Close()
+
+	client := sqstest.New()
+
+	// send traced message into input queue
+
+	ctxProducer, producerSpan := tracer.Start(context.TODO(), "producer")
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueInput),
+		MessageBody:       aws.String(`{"a":"b"}`),
+		MessageAttributes: map[string]types.MessageAttributeValue{},
+	}
+	if errInject := otelsqs.Inject(ctxProducer, input.MessageAttributes); errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+	if _, errSend := client.SendMessage(context.TODO(), input); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+	producerSpan.End()
+
+	output := SqsQueue{SqsClient: client, URL: queueOutput}
+	output.Sender = NewSqsSender(output, otelsqs.NewCarrier(), time.Millisecond, false)
+
+	app := &SqsApplication{
+		QueueInput:  SqsQueue{SqsClient: client, URL: queueInput},
+		QueueOutput: output,
+		Tracer:      tracer,
+		BackendURL:  backend.URL,
+		NackDelay:   -1,
+	}
+
+	ctxSender, cancelSender := context.WithCancel(context.Background())
+	senderDone := make(chan struct{})
+	go func() {
+		output.Sender.Run(ctxSender)
+		close(senderDone)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		SqsListener(ctx, app)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Messages(queueInput)) > 0 || len(client.Messages(queueOutput)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("message not forwarded before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+	cancelSender()
+	<-senderDone
+
+	// forwarded to HTTP and to output queue
+
+	if body, _ := backendBody.Load().(string); body != `{"a":"b"}` {
+		t.Errorf("unexpected backend body: %q", body)
+	}
+
+	forwarded := client.Messages(queueOutput)
+	if _, found := forwarded[0].MessageAttributes["b3"]; !found {
+		t.Errorf("forwarded message missing trace context: %v", forwarded[0].MessageAttributes)
+	}
+
+	// the whole flow belongs to the producer trace
+
+	traceID := producerSpan.SpanContext().TraceID()
+
+	names := map[string]bool{}
+	for _, s := range recorder.Ended() {
+		names[s.Name()] = true
+		if s.SpanContext().TraceID() != traceID {
+			t.Errorf("span %s: traceID %s mismatches producer traceID %s",
+				s.Name(), s.SpanContext().TraceID(), traceID)
+		}
+	}
+
+	for _, name := range []string{"producer", "process input", "sqsHandle", "SqsSend", "send output", "HTTPBackend"} {
+		if !names[name] {
+			t.Errorf("missing span %s, got: %v", name, names)
+		}
+	}
+}
+
+func TestSqsListenerRetryOnBackendFailure(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+
+	// backend outage
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(queueInput), MessageBody: aws.String("x")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	app := &SqsApplication{
+		QueueInput:  SqsQueue{SqsClient: client, URL: queueInput},
+		QueueOutput: SqsQueue{SqsClient: client, URL: queueOutput},
+		Tracer:      provider.Tracer("test"),
+		BackendURL:  backend.URL,
+		NackDelay:   -1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		SqsListener(ctx, app)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Messages(queueOutput)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("message not handled before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	// failed message is kept for redelivery
+
+	if left := client.Messages(queueInput); len(left) != 1 {
+		t.Errorf("expected failed message kept in input queue, got: %v", left)
+	}
+}
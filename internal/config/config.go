@@ -1,7 +1,11 @@
 // Package config loads configuration from env vars.
 package config
 
-import "github.com/udhos/opentelemetry-trace-sqs/internal/env"
+import (
+	"time"
+
+	"github.com/udhos/opentelemetry-trace-sqs/internal/env"
+)
 
 // AppConfig holds application configuration.
 type AppConfig struct {
@@ -12,6 +16,19 @@ type AppConfig struct {
 	QueueURLOutput     string
 	QueueRoleARNInput  string
 	QueueRoleARNOutput string
+	ExtractPolicyInput string
+	BodyCaptureInput   bool
+	BodyRedactInput    string
+	ShutdownTimeout    time.Duration
+	ReceiversInput     int
+	WorkersInput       int
+	VisibilityInput    time.Duration
+	NackDelayInput     time.Duration
+	BackoffInitial     time.Duration
+	BackoffMax         time.Duration
+	BackoffMultiplier  float64
+	BackoffJitter      float64
+	SendLingerOutput   time.Duration
 	BackendURL         string
 	EndpointURL        string
 }
@@ -26,6 +43,19 @@ func New() AppConfig {
 		QueueURLOutput:     env.String("QUEUE_URL_OUTPUT", ""),
 		QueueRoleARNInput:  env.String("QUEUE_ROLE_ARN_INPUT", ""),
 		QueueRoleARNOutput: env.String("QUEUE_ROLE_ARN_OUTPUT", ""),
+		ExtractPolicyInput: env.String("EXTRACT_POLICY_INPUT", "parent"),
+		BodyCaptureInput:   env.Bool("BODY_CAPTURE_INPUT", false),
+		BodyRedactInput:    env.String("BODY_REDACT_INPUT", ""),
+		ShutdownTimeout:    env.Duration("SHUTDOWN_TIMEOUT", 20*time.Second),
+		ReceiversInput:     env.Int("RECEIVERS_INPUT", 1),
+		WorkersInput:       env.Int("WORKERS_INPUT", 1),
+		VisibilityInput:    env.Duration("VISIBILITY_TIMEOUT_INPUT", 30*time.Second),
+		NackDelayInput:     env.Duration("NACK_DELAY_INPUT", -1),
+		BackoffInitial:     env.Duration("BACKOFF_INITIAL_INPUT", 200*time.Millisecond),
+		BackoffMax:         env.Duration("BACKOFF_MAX_INPUT", 20*time.Second),
+		BackoffMultiplier:  env.Float("BACKOFF_MULTIPLIER_INPUT", 2),
+		BackoffJitter:      env.Float("BACKOFF_JITTER_INPUT", 0.2),
+		SendLingerOutput:   env.Duration("SEND_LINGER_OUTPUT", 20*time.Millisecond),
 		BackendURL:         env.String("BACKEND_URL", "http://localhost:8002/send"),
 		EndpointURL:        env.String("ENDPOINT_URL", ""),
 	}
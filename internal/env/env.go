@@ -4,6 +4,8 @@ package env
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 // String extracts string from env var.
@@ -18,3 +20,71 @@ func String(name string, defaultValue string) string {
 	log.Printf("%s=[%s] using %s=%s default=%s", name, str, name, defaultValue, defaultValue)
 	return defaultValue
 }
+
+// Bool extracts boolean from env var.
+// It returns the provided defaultValue if the env var is empty or invalid.
+// The value returned is also recorded in logs.
+func Bool(name string, defaultValue bool) bool {
+	str := os.Getenv(name)
+	if str != "" {
+		value, errConv := strconv.ParseBool(str)
+		if errConv == nil {
+			log.Printf("%s=[%s] using %s=%t default=%t", name, str, name, value, defaultValue)
+			return value
+		}
+		log.Printf("bad %s=[%s]: error: %v", name, str, errConv)
+	}
+	log.Printf("%s=[%s] using %s=%t default=%t", name, str, name, defaultValue, defaultValue)
+	return defaultValue
+}
+
+// Duration extracts time.Duration from env var.
+// It returns the provided defaultValue if the env var is empty or invalid.
+// The value returned is also recorded in logs.
+func Duration(name string, defaultValue time.Duration) time.Duration {
+	str := os.Getenv(name)
+	if str != "" {
+		value, errConv := time.ParseDuration(str)
+		if errConv == nil {
+			log.Printf("%s=[%s] using %s=%v default=%v", name, str, name, value, defaultValue)
+			return value
+		}
+		log.Printf("bad %s=[%s]: error: %v", name, str, errConv)
+	}
+	log.Printf("%s=[%s] using %s=%v default=%v", name, str, name, defaultValue, defaultValue)
+	return defaultValue
+}
+
+// Int extracts int from env var.
+// It returns the provided defaultValue if the env var is empty or invalid.
+// The value returned is also recorded in logs.
+func Int(name string, defaultValue int) int {
+	str := os.Getenv(name)
+	if str != "" {
+		value, errConv := strconv.Atoi(str)
+		if errConv == nil {
+			log.Printf("%s=[%s] using %s=%d default=%d", name, str, name, value, defaultValue)
+			return value
+		}
+		log.Printf("bad %s=[%s]: error: %v", name, str, errConv)
+	}
+	log.Printf("%s=[%s] using %s=%d default=%d", name, str, name, defaultValue, defaultValue)
+	return defaultValue
+}
+
+// Float extracts float64 from env var.
+// It returns the provided defaultValue if the env var is empty or invalid.
+// The value returned is also recorded in logs.
+func Float(name string, defaultValue float64) float64 {
+	str := os.Getenv(name)
+	if str != "" {
+		value, errConv := strconv.ParseFloat(str, 64)
+		if errConv == nil {
+			log.Printf("%s=[%s] using %s=%v default=%v", name, str, name, value, defaultValue)
+			return value
+		}
+		log.Printf("bad %s=[%s]: error: %v", name, str, errConv)
+	}
+	log.Printf("%s=[%s] using %s=%v default=%v", name, str, name, defaultValue, defaultValue)
+	return defaultValue
+}
@@ -0,0 +1,90 @@
+// Package propagator holds the propagator plumbing shared by otelsqs and otelsns:
+// the default propagator, the propagator carried by context, and the
+// OTEL_PROPAGATORS env var.
+package propagator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Default holds a default propagator.
+// It is stored atomically, since carriers read it from any goroutine.
+type Default struct {
+	propagator atomic.Pointer[propagation.TextMapPropagator]
+}
+
+// NewDefault creates a default holding propagator.
+func NewDefault(propagator propagation.TextMapPropagator) *Default {
+	d := &Default{}
+	d.Set(propagator)
+	return d
+}
+
+// Set replaces the default propagator.
+func (d *Default) Set(propagator propagation.TextMapPropagator) {
+	d.propagator.Store(&propagator)
+}
+
+// Get returns the default propagator.
+func (d *Default) Get() propagation.TextMapPropagator {
+	return *d.propagator.Load()
+}
+
+// Resolve returns the propagator for a carrier: configured, if not nil,
+// then the one from ctx, then the default.
+func (d *Default) Resolve(ctx context.Context, configured propagation.TextMapPropagator) propagation.TextMapPropagator {
+	if configured != nil {
+		return configured
+	}
+	if propagator := FromContext(ctx); propagator != nil {
+		return propagator
+	}
+	return d.Get()
+}
+
+// contextKey is the context key for the propagator set with ContextWith.
+type contextKey struct{}
+
+// ContextWith returns a copy of ctx carrying propagator.
+func ContextWith(ctx context.Context, propagator propagation.TextMapPropagator) context.Context {
+	return context.WithValue(ctx, contextKey{}, propagator)
+}
+
+// FromContext returns the propagator set with ContextWith, or nil.
+func FromContext(ctx context.Context) propagation.TextMapPropagator {
+	propagator, _ := ctx.Value(contextKey{}).(propagation.TextMapPropagator)
+	return propagator
+}
+
+// FromEnv builds a propagator from the OTEL_PROPAGATORS env var.
+// It returns nil if the env var is unset or empty, and an error for unknown
+// propagator names.
+func FromEnv() (propagation.TextMapPropagator, error) {
+	names := Names(os.Getenv("OTEL_PROPAGATORS"))
+	if len(names) == 0 {
+		return nil, nil
+	}
+	propagator, errProp := autoprop.TextMapPropagator(names...)
+	if errProp != nil {
+		return nil, fmt.Errorf("OTEL_PROPAGATORS: %w", errProp)
+	}
+	return propagator, nil
+}
+
+// Names splits a comma-separated list of propagator names.
+func Names(list string) []string {
+	var names []string
+	for name := range strings.SplitSeq(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
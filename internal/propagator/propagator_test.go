@@ -0,0 +1,61 @@
+package propagator
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestNames(t *testing.T) {
+	table := []struct {
+		list     string
+		expected []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"tracecontext", []string{"tracecontext"}},
+		{" tracecontext , baggage,,xray ", []string{"tracecontext", "baggage", "xray"}},
+	}
+	for _, data := range table {
+		if got := Names(data.list); !slices.Equal(got, data.expected) {
+			t.Errorf("%q: expected %v, got %v", data.list, data.expected, got)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OTEL_PROPAGATORS", "")
+	if propagator, err := FromEnv(); propagator != nil || err != nil {
+		t.Errorf("unset: expected nil, got %v %v", propagator, err)
+	}
+
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext")
+	if propagator, err := FromEnv(); err != nil || !slices.Equal(propagator.Fields(), []string{"traceparent", "tracestate"}) {
+		t.Errorf("tracecontext: unexpected %v %v", propagator, err)
+	}
+
+	t.Setenv("OTEL_PROPAGATORS", "bogus")
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("bogus: expected error")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	d := NewDefault(b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+
+	if fields := d.Resolve(context.TODO(), nil).Fields(); !slices.Equal(fields, []string{"b3"}) {
+		t.Errorf("expected default propagator, got fields %v", fields)
+	}
+
+	ctx := ContextWith(context.TODO(), propagation.TraceContext{})
+	if _, isTC := d.Resolve(ctx, nil).(propagation.TraceContext); !isTC {
+		t.Errorf("expected propagator from context")
+	}
+
+	if _, isBaggage := d.Resolve(ctx, propagation.Baggage{}).(propagation.Baggage); !isBaggage {
+		t.Errorf("expected configured propagator")
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Package otelcarrier implements a generic carrier over any attribute map type.
+
+SQS, SNS, Lambda events and custom wrappers all keep message attributes in a
+map[string]V, differing only in the value type V. Carrier adapts any such map
+to propagation.TextMapCarrier, given an Accessor that converts between text
+and V.
+
+# Usage
+
+Define an accessor for your value type, then convert the map to a Carrier.
+
+	import (
+	    "github.com/aws/aws-lambda-go/events"
+	    "github.com/udhos/opentelemetry-trace-sqs/otelcarrier"
+	)
+
+	// lambdaAccessor converts Lambda SQS event message attributes.
+	type lambdaAccessor struct{}
+
+	func (lambdaAccessor) Text(value events.SQSMessageAttribute) string {
+	    if value.StringValue == nil {
+	        return ""
+	    }
+	    return *value.StringValue
+	}
+
+	func (lambdaAccessor) Value(text string) events.SQSMessageAttribute {
+	    return events.SQSMessageAttribute{DataType: "String", StringValue: &text}
+	}
+
+	// lambdaCarrier is a carrier for Lambda SQS event message attributes.
+	type lambdaCarrier = otelcarrier.Carrier[events.SQSMessageAttribute, lambdaAccessor]
+
+	func handleRecord(record events.SQSMessage) {
+	    ctx := propagator.Extract(context.Background(), lambdaCarrier(record.MessageAttributes))
+	    // ...
+*/
+package otelcarrier
+
+import (
+	"maps"
+	"slices"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Accessor converts between text and attribute values of type V.
+// Accessors are usually zero-size types, so that the carrier holds no state
+// besides the map.
+type Accessor[V any] interface {
+	// Text returns the text held by the attribute value.
+	Text(value V) string
+
+	// Value creates an attribute value holding the text.
+	Value(text string) V
+}
+
+// Carrier adapts a map of attribute values of type V to propagation.TextMapCarrier,
+// using accessor A to convert values.
+// Since Carrier is a map type, converting it to propagation.TextMapCarrier does
+// not allocate, and concurrent use is as safe as concurrent use of the map.
+// https://pkg.go.dev/go.opentelemetry.io/otel/propagation#TextMapCarrier
+type Carrier[V any, A Accessor[V]] map[string]V
+
+var _ propagation.TextMapCarrier = Carrier[string, StringAccessor](nil)
+
+// Get returns the value for the key.
+func (c Carrier[V, A]) Get(key string) string {
+	value, found := c[key]
+	if !found {
+		return ""
+	}
+	var accessor A
+	return accessor.Text(value)
+}
+
+// Set stores a key-value pair.
+// Set on a nil carrier does nothing.
+func (c Carrier[V, A]) Set(key, value string) {
+	if c == nil {
+		return
+	}
+	var accessor A
+	c[key] = accessor.Value(value)
+}
+
+// Keys lists the keys in the carrier.
+func (c Carrier[V, A]) Keys() []string {
+	return slices.Collect(maps.Keys(c))
+}
+
+// StringAccessor is the accessor for plain string values,
+// as in Carrier[string, StringAccessor].
+type StringAccessor struct{}
+
+// Text returns value.
+func (StringAccessor) Text(value string) string {
+	return value
+}
+
+// Value returns text.
+func (StringAccessor) Value(text string) string {
+	return text
+}
@@ -0,0 +1,112 @@
+package otelcarrier
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// wrapper is a custom attribute value type.
+type wrapper struct {
+	kind string
+	text string
+}
+
+type wrapperAccessor struct{}
+
+func (wrapperAccessor) Text(value wrapper) string {
+	return value.text
+}
+
+func (wrapperAccessor) Value(text string) wrapper {
+	return wrapper{kind: "String", text: text}
+}
+
+func (wrapperAccessor) TypedValue(text, dataType string) wrapper {
+	if dataType == "" {
+		dataType = "String"
+	}
+	return wrapper{kind: dataType, text: text}
+}
+
+type wrapperCarrier = Carrier[wrapper, wrapperAccessor]
+
+func TestCarrier(t *testing.T) {
+	attributes := map[string]wrapper{}
+
+	carrier := wrapperCarrier(attributes)
+
+	if len(carrier.Keys()) != 0 {
+		t.Errorf("expected empty carrier")
+	}
+
+	if value1 := carrier.Get("key1"); value1 != "" {
+		t.Errorf("found unexpected key key1")
+	}
+
+	carrier.Set("key1", "value1")
+	carrier.Set("key2", "value2")
+	carrier.Set("key1", "value11")
+
+	if keys := carrier.Keys(); !slices.Equal(slices.Sorted(slices.Values(keys)), []string{"key1", "key2"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	if carrier.Get("key1") != "value11" {
+		t.Errorf("wrong value for key1")
+	}
+
+	if attributes["key2"].kind != "String" {
+		t.Errorf("wrong kind for key2: %s", attributes["key2"].kind)
+	}
+
+	// nil carrier
+
+	var nilCarrier wrapperCarrier
+	nilCarrier.Set("key1", "value1")
+	if nilCarrier.Get("key1") != "" {
+		t.Errorf("found unexpected key key1 in nil carrier")
+	}
+}
+
+func TestCarrierInjectExtract(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	propagator := propagation.TraceContext{}
+
+	attributes := map[string]wrapper{}
+
+	propagator.Inject(ctx, wrapperCarrier(attributes))
+
+	sc := trace.SpanContextFromContext(propagator.Extract(context.TODO(), wrapperCarrier(attributes)))
+
+	if sc.TraceID() != traceID {
+		t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+	}
+}
+
+func TestCarrierAllocs(t *testing.T) {
+	attributes := map[string]string{"key": "value"}
+
+	var carrier propagation.TextMapCarrier
+
+	allocs := testing.AllocsPerRun(100, func() {
+		carrier = Carrier[string, StringAccessor](attributes)
+		carrier.Get("key")
+	})
+
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,51 @@
+package otelcarrier
+
+import (
+	"strings"
+)
+
+// TypedAccessor is an Accessor that can also create attribute values with a
+// given data type, such as "Binary" or a custom type like "String.trace".
+type TypedAccessor[V any] interface {
+	Accessor[V]
+
+	// TypedValue creates an attribute value holding the text with dataType.
+	// An empty dataType means the default type used by Value.
+	TypedValue(text, dataType string) V
+}
+
+// PrefixCarrier adapts a map of attribute values of type V to propagation.TextMapCarrier,
+// naming attributes with Prefix and writing them with DataType, using accessor A.
+// Keys lists only attributes named with Prefix, with the prefix removed.
+// With empty Prefix and DataType, prefer Carrier, which does not allocate.
+type PrefixCarrier[V any, A TypedAccessor[V]] struct {
+	Attributes map[string]V
+	Prefix     string
+	DataType   string
+}
+
+// Get returns the value for the key.
+func (c PrefixCarrier[V, A]) Get(key string) string {
+	return Carrier[V, A](c.Attributes).Get(c.Prefix + key)
+}
+
+// Set stores a key-value pair.
+// Set on a nil map does nothing.
+func (c PrefixCarrier[V, A]) Set(key, value string) {
+	if c.Attributes == nil {
+		return
+	}
+	var accessor A
+	c.Attributes[c.Prefix+key] = accessor.TypedValue(value, c.DataType)
+}
+
+// Keys lists the keys in the carrier.
+func (c PrefixCarrier[V, A]) Keys() []string {
+	var keys []string
+	for k := range c.Attributes {
+		if key, found := strings.CutPrefix(k, c.Prefix); found {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
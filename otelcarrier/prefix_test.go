@@ -0,0 +1,77 @@
+package otelcarrier
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type wrapperPrefixCarrier = PrefixCarrier[wrapper, wrapperAccessor]
+
+func TestPrefixCarrier(t *testing.T) {
+	attributes := map[string]wrapper{"other": {kind: "String", text: "value"}}
+
+	carrier := wrapperPrefixCarrier{Attributes: attributes, Prefix: "trace.", DataType: "String.trace"}
+
+	if len(carrier.Keys()) != 0 {
+		t.Errorf("expected empty carrier, got keys: %v", carrier.Keys())
+	}
+
+	carrier.Set("key1", "value1")
+	carrier.Set("key2", "value2")
+
+	if keys := carrier.Keys(); !slices.Equal(slices.Sorted(slices.Values(keys)), []string{"key1", "key2"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	if carrier.Get("key1") != "value1" {
+		t.Errorf("wrong value for key1")
+	}
+
+	if attr, found := attributes["trace.key2"]; !found || attr.kind != "String.trace" {
+		t.Errorf("wrong attribute for trace.key2: %v", attr)
+	}
+
+	if carrier.Get("other") != "" {
+		t.Errorf("found attribute without prefix")
+	}
+
+	// nil map
+
+	var nilCarrier wrapperPrefixCarrier
+	nilCarrier.Set("key1", "value1")
+	if nilCarrier.Get("key1") != "" {
+		t.Errorf("found unexpected key key1 in nil carrier")
+	}
+}
+
+func TestPrefixCarrierInjectExtract(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	propagator := propagation.TraceContext{}
+
+	attributes := map[string]wrapper{}
+
+	propagator.Inject(ctx, wrapperPrefixCarrier{Attributes: attributes, Prefix: "x-"})
+
+	if _, found := attributes["x-traceparent"]; !found {
+		t.Errorf("missing prefixed traceparent: %v", attributes)
+	}
+
+	sc := trace.SpanContextFromContext(propagator.Extract(context.TODO(), wrapperPrefixCarrier{Attributes: attributes, Prefix: "x-"}))
+
+	if sc.TraceID() != traceID {
+		t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+	}
+}
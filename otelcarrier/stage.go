@@ -0,0 +1,33 @@
+package otelcarrier
+
+import (
+	"maps"
+)
+
+// Merge copies staged attributes into attributes, provided that every staged
+// attribute passes check and that attributes end up holding at most limit items.
+// A limit of zero or less means no limit. Nothing is copied when Merge fails:
+// it returns the first error from check, or errLimit when the limit would be exceeded.
+//
+// Merge lets a propagator inject into an empty staged map first, so that
+// attributes a broker would refuse never reach the message.
+func Merge[V any](attributes, staged map[string]V, limit int,
+	check func(name string, value V) error, errLimit error) error {
+
+	count := len(attributes)
+	for name, value := range staged {
+		if errCheck := check(name, value); errCheck != nil {
+			return errCheck
+		}
+		if _, found := attributes[name]; !found {
+			count++
+		}
+	}
+	if limit > 0 && count > limit {
+		return errLimit
+	}
+
+	maps.Copy(attributes, staged)
+
+	return nil
+}
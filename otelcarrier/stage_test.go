@@ -0,0 +1,60 @@
+package otelcarrier
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+var (
+	errLimit   = errors.New("limit")
+	errInvalid = errors.New("invalid")
+)
+
+// checkText refuses empty values.
+func checkText(name, value string) error {
+	if value == "" {
+		return errInvalid
+	}
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	table := []struct {
+		name     string
+		staged   map[string]string
+		limit    int
+		expected error
+	}{
+		{"fits", map[string]string{"b": "2"}, 2, nil},
+		{"replaces", map[string]string{"a": "3"}, 1, nil},
+		{"unlimited", map[string]string{"b": "2", "c": "3"}, 0, nil},
+		{"over limit", map[string]string{"b": "2", "c": "3"}, 2, errLimit},
+		{"invalid", map[string]string{"b": ""}, 0, errInvalid},
+	}
+
+	for _, data := range table {
+		t.Run(data.name, func(t *testing.T) {
+			attributes := map[string]string{"a": "1"}
+			before := maps.Clone(attributes)
+
+			err := Merge(attributes, data.staged, data.limit, checkText, errLimit)
+			if !errors.Is(err, data.expected) {
+				t.Fatalf("expected error %v, got %v", data.expected, err)
+			}
+
+			if err != nil {
+				if !maps.Equal(attributes, before) {
+					t.Errorf("attributes changed on error: %v", attributes)
+				}
+				return
+			}
+
+			for k, v := range data.staged {
+				if attributes[k] != v {
+					t.Errorf("attribute %s: expected %s, got %s", k, v, attributes[k])
+				}
+			}
+		})
+	}
+}
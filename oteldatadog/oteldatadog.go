@@ -0,0 +1,189 @@
+/*
+Package oteldatadog implements a propagator for the `_datadog` message attribute.
+
+Datadog's dd-trace propagates SQS and SNS trace context as a single `_datadog`
+message attribute holding a JSON object of headers, sometimes with data type
+Binary when the message was published through SNS. This propagator reads and
+writes that format, so traces continue across dd-trace and OpenTelemetry services.
+
+# Usage
+
+Plug the propagator into the SQS or SNS carrier.
+
+	import (
+	    "github.com/udhos/opentelemetry-trace-sqs/oteldatadog"
+	    "github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	)
+
+	carrier := otelsqs.NewCarrier().WithPropagator(oteldatadog.New())
+
+	// Extract the tracing context from a message sent by dd-trace
+	ctx := carrier.Extract(context.Background(), inboundSqsMessage.MessageAttributes)
+
+	// Inject the tracing context so that dd-trace can continue it
+	if errInject := carrier.Inject(ctx, outboundSqsMessage.MessageAttributes); errInject != nil {
+	    log.Printf("inject error: %v", errInject)
+	}
+*/
+package oteldatadog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AttributeName is the message attribute used by dd-trace to carry trace context.
+const AttributeName = "_datadog"
+
+const (
+	headerTraceID          = "x-datadog-trace-id"
+	headerParentID         = "x-datadog-parent-id"
+	headerSamplingPriority = "x-datadog-sampling-priority"
+	headerTags             = "x-datadog-tags"
+
+	// tagTraceIDHigh holds the upper 64 bits of a 128-bit trace ID as hex.
+	tagTraceIDHigh = "_dd.p.tid"
+)
+
+// Propagator propagates trace context within the `_datadog` message attribute.
+// Both the Datadog headers and the W3C traceparent header are written into the
+// JSON object, matching dd-trace default propagation style.
+// https://pkg.go.dev/go.opentelemetry.io/otel/propagation#TextMapPropagator
+type Propagator struct{}
+
+var _ propagation.TextMapPropagator = Propagator{}
+
+// New creates a propagator for the `_datadog` message attribute.
+func New() Propagator {
+	return Propagator{}
+}
+
+// Inject writes the span context from ctx as a JSON object into the `_datadog` attribute.
+func (Propagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	headers := propagation.MapCarrier{}
+
+	// W3C headers preserve the full 128-bit trace ID and tracestate.
+	propagation.TraceContext{}.Inject(ctx, headers)
+
+	traceID := sc.TraceID()
+	spanID := sc.SpanID()
+
+	headers[headerTraceID] = strconv.FormatUint(binary.BigEndian.Uint64(traceID[8:]), 10)
+	headers[headerParentID] = strconv.FormatUint(binary.BigEndian.Uint64(spanID[:]), 10)
+
+	if sc.IsSampled() {
+		headers[headerSamplingPriority] = "1"
+	} else {
+		headers[headerSamplingPriority] = "0"
+	}
+
+	if high := traceID[:8]; binary.BigEndian.Uint64(high) != 0 {
+		headers[headerTags] = tagTraceIDHigh + "=" + hex.EncodeToString(high)
+	}
+
+	data, errJSON := json.Marshal(headers)
+	if errJSON != nil {
+		return
+	}
+
+	carrier.Set(AttributeName, string(data))
+}
+
+// Extract reads the span context from the `_datadog` attribute into ctx.
+// The W3C traceparent header is preferred when present, since it carries the
+// full trace ID; otherwise the Datadog headers are decoded.
+// If the attribute is missing or malformed, ctx is returned unchanged.
+func (Propagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	value := carrier.Get(AttributeName)
+	if value == "" {
+		return ctx
+	}
+
+	var headers map[string]string
+	if errJSON := json.Unmarshal([]byte(value), &headers); errJSON != nil {
+		return ctx
+	}
+
+	if headers["traceparent"] != "" {
+		ctxNew := propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(headers))
+		if trace.SpanContextFromContext(ctxNew).IsValid() {
+			return ctxNew
+		}
+	}
+
+	sc, ok := datadogSpanContext(headers)
+	if !ok {
+		return ctx
+	}
+
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// datadogSpanContext decodes the Datadog headers into a span context.
+func datadogSpanContext(headers map[string]string) (trace.SpanContext, bool) {
+	low, errTrace := strconv.ParseUint(headers[headerTraceID], 10, 64)
+	if errTrace != nil {
+		return trace.SpanContext{}, false
+	}
+
+	parent, errParent := strconv.ParseUint(headers[headerParentID], 10, 64)
+	if errParent != nil {
+		return trace.SpanContext{}, false
+	}
+
+	var traceID trace.TraceID
+	binary.BigEndian.PutUint64(traceID[8:], low)
+	if high, found := traceIDHigh(headers[headerTags]); found {
+		copy(traceID[:8], high)
+	}
+
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], parent)
+
+	var flags trace.TraceFlags
+	if priority, errPriority := strconv.Atoi(headers[headerSamplingPriority]); errPriority == nil && priority > 0 {
+		flags = trace.FlagsSampled
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+
+	return sc, sc.IsValid()
+}
+
+// traceIDHigh finds the upper 64 bits of the trace ID in the x-datadog-tags header.
+func traceIDHigh(tags string) ([]byte, bool) {
+	for tag := range strings.SplitSeq(tags, ",") {
+		key, value, found := strings.Cut(tag, "=")
+		if !found || key != tagTraceIDHigh {
+			continue
+		}
+		high, errHex := hex.DecodeString(value)
+		if errHex != nil || len(high) != 8 {
+			return nil, false
+		}
+		return high, true
+	}
+	return nil, false
+}
+
+// Fields lists the message attribute used by the propagator.
+func (Propagator) Fields() []string {
+	return []string{AttributeName}
+}
@@ -0,0 +1,164 @@
+package oteldatadog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+)
+
+func TestDatadogInjectExtract(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("6543210fedcba9870123456789abcdef")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), sc)
+
+	//
+	// Send
+	//
+
+	msg := types.Message{
+		MessageAttributes: make(map[string]types.MessageAttributeValue),
+	}
+	carrier := otelsqs.NewCarrier().WithPropagator(New())
+	if errInject := carrier.Inject(ctx, msg.MessageAttributes); errInject != nil {
+		t.Errorf("inject: %v", errInject)
+	}
+
+	if len(msg.MessageAttributes) != 1 {
+		t.Errorf("expected only attribute %s, got %d attributes", AttributeName, len(msg.MessageAttributes))
+	}
+
+	var headers map[string]string
+	if errJSON := json.Unmarshal([]byte(aws.ToString(msg.MessageAttributes[AttributeName].StringValue)), &headers); errJSON != nil {
+		t.Fatalf("json: %v", errJSON)
+	}
+
+	expectHeaders := map[string]string{
+		"x-datadog-trace-id":          "81985529216486895",          // 0x0123456789abcdef
+		"x-datadog-parent-id":         "67667974448284343",          // 0x00f067aa0ba902b7
+		"x-datadog-sampling-priority": "1",                          // sampled
+		"x-datadog-tags":              "_dd.p.tid=6543210fedcba987", // upper 64 bits
+	}
+	for k, v := range expectHeaders {
+		if headers[k] != v {
+			t.Errorf("header %s: expected %s, got %s", k, v, headers[k])
+		}
+	}
+
+	//
+	// Receive
+	//
+
+	ctxNew := carrier.Extract(context.TODO(), msg.MessageAttributes)
+
+	scRecv := trace.SpanContextFromContext(ctxNew)
+
+	if scRecv.TraceID() != traceID {
+		t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, scRecv.TraceID())
+	}
+
+	if scRecv.SpanID() != spanID {
+		t.Errorf("spanIDSent:%s mismatches spanIDRecv:%s", spanID, scRecv.SpanID())
+	}
+}
+
+func TestDatadogExtract(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		attribute     types.MessageAttributeValue
+		expectTraceID string
+		expectSpanID  string
+		expectSampled bool
+	}{
+		{
+			name: "datadog headers as binary",
+			attribute: types.MessageAttributeValue{
+				DataType:    aws.String("Binary"),
+				BinaryValue: []byte(`{"x-datadog-trace-id":"4819563617829640387","x-datadog-parent-id":"2760193457291744516","x-datadog-sampling-priority":"1"}`),
+			},
+			expectTraceID: "000000000000000042e287934521d8c3",
+			expectSpanID:  "264e2d53453b5504",
+			expectSampled: true,
+		},
+		{
+			name: "datadog headers with 128-bit trace id",
+			attribute: types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(`{"x-datadog-trace-id":"4819563617829640387","x-datadog-parent-id":"2760193457291744516","x-datadog-sampling-priority":"0","x-datadog-tags":"_dd.p.dm=-0,_dd.p.tid=66f2a3b400000000"}`),
+			},
+			expectTraceID: "66f2a3b40000000042e287934521d8c3",
+			expectSpanID:  "264e2d53453b5504",
+			expectSampled: false,
+		},
+		{
+			name: "traceparent preferred",
+			attribute: types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(`{"x-datadog-trace-id":"1","x-datadog-parent-id":"2","traceparent":"00-66f2a3b40000000042e287934521d8c3-264e2d53453b5504-01"}`),
+			},
+			expectTraceID: "66f2a3b40000000042e287934521d8c3",
+			expectSpanID:  "264e2d53453b5504",
+			expectSampled: true,
+		},
+		{
+			name: "malformed json",
+			attribute: types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(`{"x-datadog-trace-id":`),
+			},
+		},
+		{
+			name: "zero trace id",
+			attribute: types.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(`{"x-datadog-trace-id":"0","x-datadog-parent-id":"2"}`),
+			},
+		},
+	}
+
+	carrier := otelsqs.NewCarrier().WithPropagator(New())
+
+	for _, data := range testCases {
+		t.Run(data.name, func(t *testing.T) {
+			attributes := map[string]types.MessageAttributeValue{
+				AttributeName: data.attribute,
+			}
+
+			sc := trace.SpanContextFromContext(carrier.Extract(context.TODO(), attributes))
+
+			if data.expectTraceID == "" {
+				if sc.IsValid() {
+					t.Errorf("unexpected valid span context: %v", sc)
+				}
+				return
+			}
+
+			if sc.TraceID().String() != data.expectTraceID {
+				t.Errorf("expected traceID:%s got traceID:%s", data.expectTraceID, sc.TraceID())
+			}
+			if sc.SpanID().String() != data.expectSpanID {
+				t.Errorf("expected spanID:%s got spanID:%s", data.expectSpanID, sc.SpanID())
+			}
+			if sc.IsSampled() != data.expectSampled {
+				t.Errorf("expected sampled:%t got sampled:%t", data.expectSampled, sc.IsSampled())
+			}
+			if !sc.IsRemote() {
+				t.Errorf("expected remote span context")
+			}
+		})
+	}
+}
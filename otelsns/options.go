@@ -0,0 +1,61 @@
+package otelsns
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a carrier created with NewCarrier.
+type Option func(c *SnsCarrierAttributes)
+
+// WithPropagator sets propagator for carrier.
+// If unspecified, carrier uses the propagator from context set with ContextWithPropagator,
+// or else the default propagator defined with SetTextMapPropagator.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.WithPropagator(propagator)
+	}
+}
+
+// WithKeyPrefix prepends prefix to the message attribute names read and written
+// by the carrier, for instance "otel." turns "traceparent" into "otel.traceparent".
+func WithKeyPrefix(prefix string) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.keyPrefix = prefix
+	}
+}
+
+// WithMaxAttributes sets the maximum number of message attributes.
+// Inject refuses messages already holding limit attributes or more.
+// Defaults to no limit.
+func WithMaxAttributes(limit int) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.maxAttributes = limit
+	}
+}
+
+// WithDataType sets the data type for message attributes written by the carrier,
+// for instance "String" or "Binary". Custom types such as "String.trace" are accepted.
+// Defaults to "String".
+func WithDataType(dataType string) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.dataType = dataType
+	}
+}
+
+// WithValidation enables Inject to check attributes written by the propagator
+// against SNS rules for attribute names, values and count, returning
+// ErrInvalidAttribute or ErrMaxAttrLimit instead of producing a message that SNS would refuse.
+func WithValidation(enable bool) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.validate = enable
+	}
+}
+
+// WithTracerProvider sets the tracer provider for spans started by StartProducerSpan.
+// Defaults to the global tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *SnsCarrierAttributes) {
+		c.tracerProvider = provider
+	}
+}
@@ -0,0 +1,73 @@
+package otelsns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func testContext() context.Context {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	return trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func TestOptionKeyPrefixDataType(t *testing.T) {
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}),
+		WithKeyPrefix("otel."), WithDataType("Binary"))
+
+	attributes := map[string]types.MessageAttributeValue{}
+
+	if errInject := carrier.Inject(testContext(), attributes); errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+
+	attr, found := attributes["otel.traceparent"]
+	if !found {
+		t.Fatalf("missing prefixed attribute: %v", attributes)
+	}
+	if aws.ToString(attr.DataType) != "Binary" || attr.StringValue != nil || len(attr.BinaryValue) == 0 {
+		t.Errorf("expected binary attribute, got: %+v", attr)
+	}
+}
+
+func TestOptionMaxAttributes(t *testing.T) {
+	attributes := map[string]types.MessageAttributeValue{
+		"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+	}
+
+	// no limit by default
+
+	if errInject := NewCarrier(WithPropagator(propagation.TraceContext{})).Inject(testContext(), attributes); errInject != nil {
+		t.Errorf("inject: %v", errInject)
+	}
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMaxAttributes(2))
+
+	if errInject := carrier.Inject(testContext(), attributes); !errors.Is(errInject, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", errInject)
+	}
+}
+
+func TestOptionValidation(t *testing.T) {
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}),
+		WithKeyPrefix("amazon."), WithValidation(true))
+
+	attributes := map[string]types.MessageAttributeValue{}
+
+	if errInject := carrier.Inject(testContext(), attributes); !errors.Is(errInject, ErrInvalidAttribute) {
+		t.Errorf("expected ErrInvalidAttribute, got: %v", errInject)
+	}
+	if len(attributes) != 0 {
+		t.Errorf("expected attributes unchanged, got: %v", attributes)
+	}
+}
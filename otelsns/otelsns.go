@@ -27,105 +27,240 @@ Use `SnsCarrierAttributes.Inject` to inject trace context into SNS publishing.
 	    }
 
 	    // Now invoke SNS publish for input
+
+Alternatively, use SnsCarrierAttributes.StartProducerSpan to start a producer
+span for the publish and inject its context, using the tracer provider set with
+WithTracerProvider.
 */
 package otelsns
 
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/udhos/opentelemetry-trace-sqs/internal/propagator"
+	"github.com/udhos/opentelemetry-trace-sqs/otelcarrier"
 	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var defaultSnsPropagator = b3.New() // b3 single header
+// defaultSnsPropagator holds the default propagator.
+var defaultSnsPropagator = propagator.NewDefault(b3.New()) // b3 single header
+
+// SetTextMapPropagator optionally replaces the default SNS propagator (B3 with single header).
+// It is independent from the otelsqs default. SNS accepts at most 10 message
+// attributes, so prefer propagators writing few of them.
+// It is safe for concurrent use with carriers.
+func SetTextMapPropagator(p propagation.TextMapPropagator) {
+	defaultSnsPropagator.Set(p)
+}
+
+// ContextWithPropagator returns a copy of ctx carrying a propagator that overrides
+// the default one for carriers invoked with that context, unless set with WithPropagator.
+// It is the same context value as otelsqs.ContextWithPropagator, so one call
+// covers both SQS and SNS carriers.
+func ContextWithPropagator(ctx context.Context, p propagation.TextMapPropagator) context.Context {
+	return propagator.ContextWith(ctx, p)
+}
+
+// PropagatorFromContext returns the propagator set with ContextWithPropagator, or nil.
+func PropagatorFromContext(ctx context.Context) propagation.TextMapPropagator {
+	return propagator.FromContext(ctx)
+}
+
+// SetTextMapPropagatorFromEnv sets the default SNS propagator from OTEL_PROPAGATORS,
+// accepting the same names as otelsqs.SetTextMapPropagatorFromEnv.
+// The default is unchanged when the env var is unset; unknown names are an error.
+func SetTextMapPropagatorFromEnv() error {
+	p, errEnv := propagator.FromEnv()
+	if p != nil {
+		SetTextMapPropagator(p)
+	}
+	return errEnv
+}
 
-// SetTextMapPropagator optionally replaces the default propagator (B3 with single header).
-// Please notice that SNS only supports up to 10 attributes, then be careful when picking
-// another propagator that might consume multiple attributes.
-func SetTextMapPropagator(propagator propagation.TextMapPropagator) {
-	defaultSnsPropagator = propagator
+// SetTextMapPropagatorFromGlobal sets the default SNS propagator to
+// otel.GetTextMapPropagator(). Call it once the global propagator is configured.
+func SetTextMapPropagatorFromGlobal() {
+	SetTextMapPropagator(otel.GetTextMapPropagator())
 }
 
 // SnsCarrierAttributes is a message attribute carrier for SNS.
-// https://pkg.go.dev/go.opentelemetry.io/otel/propagation#TextMapCarrier
+// Once configured, the carrier is not mutated by Inject, so it can be shared
+// by concurrent workers.
+// To read or write message attributes as a TextMapCarrier, use MessageAttributesCarrier.
 type SnsCarrierAttributes struct {
-	messageAttributes map[string]types.MessageAttributeValue
-	propagator        propagation.TextMapPropagator
+	propagator     propagation.TextMapPropagator
+	dataType       string
+	keyPrefix      string
+	maxAttributes  int
+	validate       bool
+	tracerProvider trace.TracerProvider
 }
 
 // NewCarrier creates a carrier for SNS.
-func NewCarrier() *SnsCarrierAttributes {
+// Options are applied in order, see Option.
+//
+// Example:
+//
+//	carrier := otelsns.NewCarrier(otelsns.WithPropagator(propagation.TraceContext{}), otelsns.WithMaxAttributes(10))
+func NewCarrier(options ...Option) *SnsCarrierAttributes {
 	c := &SnsCarrierAttributes{}
-	return c.WithPropagator(defaultSnsPropagator)
+	for _, o := range options {
+		o(c)
+	}
+	return c
 }
 
-// WithPropagator sets propagator for carrier. If unspecified, carrier uses default propagator defined with SetTextMapPropagator.
+// WithPropagator sets propagator for carrier.
+// If unspecified, carrier uses the propagator from context set with ContextWithPropagator,
+// or else the default propagator defined with SetTextMapPropagator.
 func (c *SnsCarrierAttributes) WithPropagator(propagator propagation.TextMapPropagator) *SnsCarrierAttributes {
 	c.propagator = propagator
 	return c
 }
 
-// attach attaches carrier to SNS input.
-func (c *SnsCarrierAttributes) attach(messageAttributes map[string]types.MessageAttributeValue) {
-	if messageAttributes == nil {
-		panic("messageAttributes map is nil")
-	}
-	c.messageAttributes = messageAttributes
+// getPropagator resolves the propagator for carrier: the one set with WithPropagator,
+// then the one from ctx, then the default.
+func (c *SnsCarrierAttributes) getPropagator(ctx context.Context) propagation.TextMapPropagator {
+	return defaultSnsPropagator.Resolve(ctx, c.propagator)
 }
 
 // Inject inserts tracing from context into the SNS message attributes.
 // `ctx` holds current context with trace information.
 // `messageAttributes` should point to outgoing SNS publish MessageAttributes which will carry the trace information.
 // If `messageAttributes` is nil, error ErrMessageAttributesIsNil will be returned.
+// If a limit is set with WithMaxAttributes and `messageAttributes` holds that many items or more,
+// Inject will do nothing and return ErrMaxAttrLimit.
+// With WithValidation, Inject also returns ErrMaxAttrLimit or ErrInvalidAttribute when attributes
+// written by the propagator would be refused, leaving `messageAttributes` unchanged.
 // Use Inject right before publishing out to SNS.
 func (c *SnsCarrierAttributes) Inject(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) error {
 	if messageAttributes == nil {
 		return ErrMessageAttributesIsNil
 	}
-	c.attach(messageAttributes)
-	c.propagator.Inject(ctx, c)
-	return nil
-}
+	if c.maxAttributes > 0 && len(messageAttributes) >= c.maxAttributes {
+		return ErrMaxAttrLimit
+	}
 
-// ErrMessageAttributesIsNil rejects nil message attributes.
-var ErrMessageAttributesIsNil = errors.New("message attributes is nil")
+	propagator := c.getPropagator(ctx)
 
-// Get returns the value for the key.
-func (c *SnsCarrierAttributes) Get(key string) string {
-	if c.messageAttributes == nil {
-		return ""
+	if !c.validate {
+		propagator.Inject(ctx, c.carrier(messageAttributes))
+		return nil
 	}
-	attr, found := c.messageAttributes[key]
-	if !found {
-		return ""
+
+	// stage attributes, so that nothing is written when validation fails
+	staged := map[string]types.MessageAttributeValue{}
+
+	propagator.Inject(ctx, c.carrier(staged))
+
+	return otelcarrier.Merge(messageAttributes, staged, c.maxAttributes, validateAttribute, ErrMaxAttrLimit)
+}
+
+// carrier adapts message attributes according to the carrier configuration.
+func (c *SnsCarrierAttributes) carrier(messageAttributes map[string]types.MessageAttributeValue) propagation.TextMapCarrier {
+	if c.keyPrefix == "" && (c.dataType == "" || c.dataType == stringType) {
+		return MessageAttributesCarrier(messageAttributes) // no allocation
 	}
-	if attr.StringValue == nil {
-		return ""
+	return prefixCarrier{
+		Attributes: messageAttributes,
+		Prefix:     c.keyPrefix,
+		DataType:   c.dataType,
 	}
-	return *attr.StringValue
 }
 
-const stringType = "String"
+// Inject inserts tracing from context into the SNS message attributes, using the
+// propagator from ctx set with ContextWithPropagator, or else the default propagator.
+// It is safe for concurrent use. See SnsCarrierAttributes.Inject.
+func Inject(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) error {
+	var c SnsCarrierAttributes
+	return c.Inject(ctx, messageAttributes)
+}
+
+// MessageAttributesCarrier adapts SNS message attributes to propagation.TextMapCarrier.
+// It holds no state besides the map itself, hence concurrent use is as safe as concurrent
+// use of the underlying map. Set stores attributes with data type String.
+// It also fits SNS PublishBatch entries, which share the message attribute value type.
+type MessageAttributesCarrier = otelcarrier.Carrier[types.MessageAttributeValue, AttributeAccessor]
+
+// AttributeAccessor converts SNS message attribute values for otelcarrier.Carrier.
+type AttributeAccessor struct{}
 
-// Set stores a key-value pair.
-func (c *SnsCarrierAttributes) Set(key, value string) {
-	if c.messageAttributes == nil {
-		return
+// Text returns the attribute value, either String or Binary, as text.
+// Binary attributes, as sent by some tracers, are returned as text.
+func (AttributeAccessor) Text(value types.MessageAttributeValue) string {
+	if value.StringValue == nil {
+		return string(value.BinaryValue)
 	}
-	c.messageAttributes[key] = types.MessageAttributeValue{
+	return *value.StringValue
+}
+
+// Value creates an attribute value with data type String.
+func (AttributeAccessor) Value(text string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
 		DataType:    aws.String(stringType),
-		StringValue: aws.String(value),
+		StringValue: aws.String(text),
 	}
 }
 
-// Keys lists the keys in the carrier.
-func (c *SnsCarrierAttributes) Keys() []string {
-	keys := make([]string, 0, len(c.messageAttributes))
-	for k := range c.messageAttributes {
-		keys = append(keys, k)
+// TypedValue creates an attribute value with dataType, or String if dataType is empty.
+// Binary types hold the text as BinaryValue.
+func (AttributeAccessor) TypedValue(text, dataType string) types.MessageAttributeValue {
+	if dataType == "" {
+		dataType = stringType
 	}
-	return keys
+	if strings.HasPrefix(dataType, binaryType) {
+		return types.MessageAttributeValue{
+			DataType:    aws.String(dataType),
+			BinaryValue: []byte(text),
+		}
+	}
+	return types.MessageAttributeValue{
+		DataType:    aws.String(dataType),
+		StringValue: aws.String(text),
+	}
+}
+
+// prefixCarrier adapts message attributes named with prefix and written with data type.
+type prefixCarrier = otelcarrier.PrefixCarrier[types.MessageAttributeValue, AttributeAccessor]
+
+var (
+	// ErrMessageAttributesIsNil rejects nil message attributes.
+	ErrMessageAttributesIsNil = errors.New("message attributes is nil")
+
+	// ErrMaxAttrLimit signals max attribute limit reached.
+	ErrMaxAttrLimit = errors.New("max attribute limit reached")
+
+	// ErrInvalidAttribute signals an attribute that SNS would refuse.
+	ErrInvalidAttribute = errors.New("invalid message attribute")
+)
+
+// Get always returns an empty string, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(input.MessageAttributes).Get instead.
+func (c *SnsCarrierAttributes) Get(key string) string {
+	return ""
+}
+
+const (
+	stringType = "String"
+	binaryType = "Binary"
+)
+
+// Set does nothing, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(input.MessageAttributes).Set instead.
+func (c *SnsCarrierAttributes) Set(key, value string) {}
+
+// Keys always returns nil, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(input.MessageAttributes).Keys instead.
+func (c *SnsCarrierAttributes) Keys() []string {
+	return nil
 }
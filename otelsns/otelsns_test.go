@@ -3,12 +3,17 @@ package otelsns
 import (
 	"context"
 	"log"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sns/types"
 	sqs_types "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
@@ -158,8 +163,7 @@ func TestSnsCarrierAttributes(t *testing.T) {
 	input := sns.PublishInput{
 		MessageAttributes: make(map[string]types.MessageAttributeValue),
 	}
-	carrier := NewCarrier()
-	carrier.attach(input.MessageAttributes)
+	carrier := MessageAttributesCarrier(input.MessageAttributes)
 
 	// no keys
 
@@ -263,3 +267,117 @@ func TestSnsCarrierAttributes(t *testing.T) {
 		t.Errorf("wrong value for key3")
 	}
 }
+
+func TestSetTextMapPropagatorFromEnv(t *testing.T) {
+	saved := defaultSnsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	testCases := []struct {
+		name         string
+		env          string
+		expectFields []string
+		expectError  bool
+	}{
+		{"unset keeps default", "", nil, false},
+		{"tracecontext", "tracecontext", []string{"traceparent", "tracestate"}, false},
+		{"list with spaces", " tracecontext , xray ", []string{"X-Amzn-Trace-Id", "traceparent", "tracestate"}, false},
+		{"unknown", "tracecontext,unknown", nil, true},
+	}
+
+	for _, data := range testCases {
+		t.Run(data.name, func(t *testing.T) {
+			SetTextMapPropagator(saved)
+			t.Setenv("OTEL_PROPAGATORS", data.env)
+
+			errEnv := SetTextMapPropagatorFromEnv()
+			if (errEnv != nil) != data.expectError {
+				t.Errorf("expected error:%t got error:%v", data.expectError, errEnv)
+			}
+
+			expectFields := data.expectFields
+			if expectFields == nil {
+				expectFields = slices.Sorted(slices.Values(saved.Fields())) // default unchanged
+			}
+
+			fields := NewCarrier().getPropagator(context.TODO()).Fields()
+			slices.Sort(fields) // composite propagator fields are unordered
+			if !slices.Equal(fields, expectFields) {
+				t.Errorf("expected fields:%v got fields:%v", expectFields, fields)
+			}
+		})
+	}
+}
+
+func TestSetTextMapPropagatorFromGlobal(t *testing.T) {
+	saved := defaultSnsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	savedGlobal := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(savedGlobal) })
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	SetTextMapPropagatorFromGlobal()
+
+	fields := NewCarrier().getPropagator(context.TODO()).Fields()
+	slices.Sort(fields)
+	if expect := []string{"traceparent", "tracestate"}; !slices.Equal(fields, expect) {
+		t.Errorf("expected fields:%v got fields:%v", expect, fields)
+	}
+}
+
+func TestPropagatorConcurrency(t *testing.T) {
+	saved := defaultSnsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// keep swapping the global default
+	wg.Go(func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				SetTextMapPropagator(propagation.TraceContext{})
+			} else {
+				SetTextMapPropagator(b3.New())
+			}
+		}
+	})
+
+	var workers sync.WaitGroup
+
+	for range 8 {
+		workers.Go(func() {
+			ctxScoped := ContextWithPropagator(ctx, propagation.TraceContext{})
+			for range 100 {
+				attributes := make(map[string]types.MessageAttributeValue)
+				if errInject := NewCarrier().Inject(ctxScoped, attributes); errInject != nil {
+					t.Errorf("inject: %v", errInject)
+					return
+				}
+				if _, found := attributes["traceparent"]; !found {
+					t.Errorf("scoped propagator: missing key traceparent: %v", attributes)
+					return
+				}
+			}
+		})
+	}
+
+	workers.Wait()
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,89 @@
+package otelsns
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/udhos/opentelemetry-trace-sqs/otelsns"
+
+// StartProducerSpan starts a producer span for publishing input, then injects the
+// span context into input.MessageAttributes with Inject, so that subscribers continue
+// the trace from the producer span. A nil input.MessageAttributes map is created.
+// If injection fails, the error is recorded on the span and returned, but the span
+// is still started: the caller may publish the message anyway.
+// The caller must end the returned span after publishing the message.
+//
+// Example:
+//
+//	ctx, span, errInject := carrier.StartProducerSpan(ctx, input)
+//	if errInject != nil {
+//	    log.Printf("inject error: %v", errInject)
+//	}
+//	_, errPublish := client.Publish(ctx, input)
+//	span.End()
+func (c *SnsCarrierAttributes) StartProducerSpan(ctx context.Context, input *sns.PublishInput,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span, error) {
+
+	topic := topicName(aws.ToString(input.TopicArn))
+	if topic == "" {
+		topic = topicName(aws.ToString(input.TargetArn))
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemAWSSNS,
+		semconv.MessagingOperationTypeSend,
+		semconv.MessagingOperationName("publish"),
+	}
+	name := "publish"
+	if topic != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(topic))
+		name += " " + topic
+	}
+
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	}
+
+	ctxSpan, span := c.tracer().Start(ctx, name, append(options, opts...)...)
+
+	if input.MessageAttributes == nil {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue)
+	}
+
+	errInject := c.Inject(ctxSpan, input.MessageAttributes)
+	if errInject != nil {
+		span.RecordError(errInject)
+		span.SetStatus(codes.Error, errInject.Error())
+	}
+
+	return ctxSpan, span, errInject
+}
+
+// tracer returns the tracer for spans started by the carrier.
+func (c *SnsCarrierAttributes) tracer() trace.Tracer {
+	provider := c.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// topicName extracts the topic name from the topic ARN.
+// arn:aws:sns:us-east-1:123456789012:topic_name -> topic_name
+func topicName(topicArn string) string {
+	if i := strings.LastIndexByte(topicArn, ':'); i >= 0 {
+		return topicArn[i+1:]
+	}
+	return topicArn
+}
@@ -0,0 +1,79 @@
+package otelsns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTopicArn = "arn:aws:sns:us-east-1:123456789012:orders"
+
+func TestStartProducerSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithTracerProvider(provider))
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(testTopicArn),
+		Message:  aws.String("hello"),
+	}
+
+	_, span, errInject := carrier.StartProducerSpan(context.TODO(), input)
+	if errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+	span.End()
+
+	s := recorder.Ended()[0]
+
+	if s.Name() != "publish orders" || s.SpanKind() != trace.SpanKindProducer {
+		t.Errorf("unexpected span: name=%s kind=%v", s.Name(), s.SpanKind())
+	}
+
+	// subscribers continue from the producer span
+
+	sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.TODO(),
+		MessageAttributesCarrier(input.MessageAttributes)))
+	if sc.SpanID() != s.SpanContext().SpanID() {
+		t.Errorf("extracted spanID:%s mismatches producer spanID:%s", sc.SpanID(), s.SpanContext().SpanID())
+	}
+}
+
+func TestStartProducerSpanInjectError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithTracerProvider(provider), WithMaxAttributes(1))
+
+	input := &sns.PublishInput{
+		TargetArn: aws.String(testTopicArn),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+		},
+	}
+
+	_, span, errInject := carrier.StartProducerSpan(context.TODO(), input)
+	if !errors.Is(errInject, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", errInject)
+	}
+	span.End()
+
+	s := recorder.Ended()[0]
+
+	if s.Name() != "publish orders" {
+		t.Errorf("unexpected span name: %s", s.Name())
+	}
+	if status := s.Status(); status.Code != codes.Error {
+		t.Errorf("expected error status, got: %v", status)
+	}
+}
@@ -0,0 +1,129 @@
+package otelsqs
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BodyEvent is the name of the span event holding the captured message body.
+const BodyEvent = "message.body"
+
+// BodyAttribute is the span event attribute holding the captured message body,
+// after redaction and truncation.
+const BodyAttribute = "messaging.sqs.message.body"
+
+// Redacted replaces redacted content in captured bodies.
+const Redacted = "[REDACTED]"
+
+// defaultBodyMaxLength is the default maximum length for captured bodies.
+const defaultBodyMaxLength = 1024
+
+// Patterns for common personal data, to use in BodyCapture.RedactPatterns.
+var (
+	// RedactEmail matches email addresses.
+	RedactEmail = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// RedactCardNumber matches payment card numbers, with optional space or dash separators.
+	RedactCardNumber = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// BodyCapture configures recording of message bodies on spans, see WithBodyCapture.
+// Redaction is applied before truncation, hence before anything leaves the process.
+type BodyCapture struct {
+	// MaxLength is the maximum length in bytes of the captured body.
+	// Longer bodies are truncated. Defaults to 1024.
+	MaxLength int
+
+	// RedactFields lists JSON field paths whose values are replaced with Redacted,
+	// when the body is a JSON document. Path elements are separated by dots, as in
+	// "customer.email". Arrays along the path are traversed element by element.
+	// Since the fields cannot be found in bodies that are not valid JSON, such
+	// bodies are replaced with Redacted as a whole when RedactFields is set.
+	RedactFields []string
+
+	// RedactPatterns are regular expressions whose matches are replaced with Redacted,
+	// for instance RedactEmail and RedactCardNumber. They apply to any body,
+	// JSON or not.
+	RedactPatterns []*regexp.Regexp
+}
+
+// Redact applies redaction and truncation to body, returning the text to be captured.
+func (b BodyCapture) Redact(body string) string {
+	if len(b.RedactFields) > 0 {
+		redacted, isJSON := redactFields(body, b.RedactFields)
+		if !isJSON {
+			return Redacted
+		}
+		body = redacted
+	}
+
+	for _, pattern := range b.RedactPatterns {
+		body = pattern.ReplaceAllString(body, Redacted)
+	}
+
+	maxLength := b.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultBodyMaxLength
+	}
+
+	return truncate(body, maxLength)
+}
+
+// redactFields replaces values at JSON field paths.
+// It reports false for bodies that are not a JSON object or array.
+func redactFields(body string, paths []string) (string, bool) {
+	trimmed := strings.TrimSpace(body)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+
+	var doc any
+	if errJSON := json.Unmarshal([]byte(trimmed), &doc); errJSON != nil {
+		return "", false
+	}
+
+	for _, path := range paths {
+		doc = redactPath(doc, strings.Split(path, "."))
+	}
+
+	data, errJSON := json.Marshal(doc)
+	if errJSON != nil {
+		return "", false
+	}
+
+	return string(data), true
+}
+
+// redactPath replaces the value at path within node.
+func redactPath(node any, path []string) any {
+	if len(path) == 0 {
+		return Redacted
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		if child, found := n[path[0]]; found {
+			n[path[0]] = redactPath(child, path[1:])
+		}
+	case []any:
+		for i, child := range n {
+			n[i] = redactPath(child, path)
+		}
+	}
+	return node
+}
+
+// recordBody adds the captured body as a span event, when enabled with WithBodyCapture.
+func (c *SqsCarrierAttributes) recordBody(span trace.Span, body *string) {
+	if c.bodyCapture == nil || body == nil {
+		return
+	}
+	span.AddEvent(BodyEvent, trace.WithAttributes(
+		attribute.String(BodyAttribute, c.bodyCapture.Redact(*body)),
+		semconv.MessagingMessageBodySize(len(*body)),
+	))
+}
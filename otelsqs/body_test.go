@@ -0,0 +1,105 @@
+package otelsqs
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBodyCaptureRedact(t *testing.T) {
+	capture := BodyCapture{
+		RedactFields:   []string{"customer.email", "items.sku", "missing.field"},
+		RedactPatterns: []*regexp.Regexp{RedactEmail, RedactCardNumber},
+	}
+
+	table := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "json fields",
+			body:     `{"customer":{"email":"x","name":"ann"},"items":[{"sku":"a1","qty":1},{"sku":"b2"}]}`,
+			expected: `{"customer":{"email":"[REDACTED]","name":"ann"},"items":[{"qty":1,"sku":"[REDACTED]"},{"sku":"[REDACTED]"}]}`,
+		},
+		{
+			name:     "patterns in json",
+			body:     `{"note":"mail bob@example.org"}`,
+			expected: `{"note":"mail [REDACTED]"}`,
+		},
+		{
+			name:     "invalid json dropped",
+			body:     `{"customer":`,
+			expected: Redacted,
+		},
+		{
+			name:     "text dropped",
+			body:     "customer.email=ann",
+			expected: Redacted,
+		},
+	}
+
+	for _, data := range table {
+		if got := capture.Redact(data.body); got != data.expected {
+			t.Errorf("%s: expected %s, got %s", data.name, data.expected, got)
+		}
+	}
+
+	patterns := BodyCapture{RedactPatterns: []*regexp.Regexp{RedactEmail, RedactCardNumber}}
+	text := "contact ann@example.com card 4111 1111 1111 1111 order 42"
+	if got := patterns.Redact(text); got != "contact [REDACTED] card [REDACTED] order 42" {
+		t.Errorf("patterns in text: got %s", got)
+	}
+
+	if got := (BodyCapture{MaxLength: 4}).Redact("abcdefgh"); got != "abcd" {
+		t.Errorf("expected truncation, got %q", got)
+	}
+	if got := (BodyCapture{}).Redact(strings.Repeat("x", 2000)); len(got) != defaultBodyMaxLength {
+		t.Errorf("expected default truncation, got length %d", len(got))
+	}
+}
+
+func TestBodyCaptureSpanEvent(t *testing.T) {
+	msg := types.Message{Body: aws.String("from ann@example.com")}
+
+	// off by default
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, span := NewCarrier(WithTracerProvider(provider)).StartConsumerSpan(context.TODO(), testQueueURL, msg)
+	span.End()
+
+	if events := recorder.Ended()[0].Events(); len(events) != 0 {
+		t.Errorf("unexpected events: %v", events)
+	}
+
+	// enabled
+
+	recorder = tracetest.NewSpanRecorder()
+	provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithTracerProvider(provider),
+		WithBodyCapture(BodyCapture{RedactPatterns: []*regexp.Regexp{RedactEmail}}))
+
+	_, span = carrier.StartConsumerSpan(context.TODO(), testQueueURL, msg)
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 1 || events[0].Name != BodyEvent {
+		t.Fatalf("expected body event, got: %v", events)
+	}
+	body, found := findAttribute(events[0].Attributes, BodyAttribute)
+	if !found || body.AsString() != "from [REDACTED]" {
+		t.Errorf("unexpected captured body: %q", body.AsString())
+	}
+	if size, _ := findAttribute(events[0].Attributes, "messaging.message.body.size"); size.AsInt64() != 20 {
+		t.Errorf("unexpected body size: %d", size.AsInt64())
+	}
+}
@@ -0,0 +1,60 @@
+package otelsqs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MessageAttributePrefix prefixes span attributes holding message attributes
+// captured with WithCapturedAttributes, as in "messaging.sqs.message_attribute.tenant".
+const MessageAttributePrefix = "messaging.sqs.message_attribute."
+
+// defaultCaptureMaxLength is the default maximum length for captured values.
+const defaultCaptureMaxLength = 128
+
+// capturedAttributes returns span attributes for the allowlisted message attributes.
+func (c *SqsCarrierAttributes) capturedAttributes(messageAttributes map[string]types.MessageAttributeValue) []attribute.KeyValue {
+	if len(c.captureNames) == 0 || len(messageAttributes) == 0 {
+		return nil
+	}
+
+	maxLength := c.captureMaxLength
+	if maxLength <= 0 {
+		maxLength = defaultCaptureMaxLength
+	}
+
+	var attrs []attribute.KeyValue
+
+	for _, name := range c.captureNames {
+		value, found := messageAttributes[name]
+		if !found {
+			continue
+		}
+		text := AttributeAccessor{}.Text(value)
+		if c.captureHash[name] {
+			mac := hmac.New(sha256.New, c.captureHashKey)
+			mac.Write([]byte(text))
+			text = hex.EncodeToString(mac.Sum(nil))
+		}
+		attrs = append(attrs, attribute.String(MessageAttributePrefix+name, truncate(text, maxLength)))
+	}
+
+	return attrs
+}
+
+// truncate cuts s to at most maxLength bytes, without splitting UTF-8 characters.
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
@@ -0,0 +1,100 @@
+package otelsqs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCapturedAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithTracerProvider(provider),
+		WithCapturedAttributes("tenant", "eventType", "absent"),
+		WithHashedAttributes([]byte("key"), "correlationId"),
+		WithCapturedAttributeMaxLength(8))
+
+	msg := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant":        {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"eventType":     {DataType: aws.String("String"), StringValue: aws.String("OrderCreated")},
+			"correlationId": {DataType: aws.String("String"), StringValue: aws.String("secret")},
+			"other":         {DataType: aws.String("String"), StringValue: aws.String("ignored")},
+		},
+	}
+
+	_, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, msg)
+	span.End()
+
+	attrs := recorder.Ended()[0].Attributes()
+
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("secret"))
+	sum := mac.Sum(nil)
+
+	expected := map[string]string{
+		"tenant":        "acme",
+		"eventType":     "OrderCre",
+		"correlationId": hex.EncodeToString(sum)[:8],
+	}
+
+	for name, value := range expected {
+		got, found := findAttribute(attrs, MessageAttributePrefix+name)
+		if !found {
+			t.Errorf("missing captured attribute %s", name)
+			continue
+		}
+		if got.AsString() != value {
+			t.Errorf("%s: expected %q, got %q", name, value, got.AsString())
+		}
+	}
+
+	for _, name := range []string{"absent", "other"} {
+		if _, found := findAttribute(attrs, MessageAttributePrefix+name); found {
+			t.Errorf("unexpected captured attribute %s", name)
+		}
+	}
+}
+
+func TestHashedAttributesWithoutKey(t *testing.T) {
+	carrier := NewCarrier(WithHashedAttributes(nil, "correlationId"))
+
+	attrs := carrier.capturedAttributes(map[string]types.MessageAttributeValue{
+		"correlationId": {DataType: aws.String("String"), StringValue: aws.String("secret")},
+	})
+
+	if len(attrs) != 0 {
+		t.Errorf("expected no captured attributes without key, got %v", attrs)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	table := []struct {
+		input     string
+		maxLength int
+		expected  string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"aé", 2, "a"}, // é is 2 bytes
+		{"aé", 3, "aé"},
+	}
+	for _, data := range table {
+		if got := truncate(data.input, data.maxLength); got != data.expected {
+			t.Errorf("truncate(%q,%d): expected %q, got %q", data.input, data.maxLength, data.expected, got)
+		}
+	}
+
+	if got := truncate(strings.Repeat("x", 200), defaultCaptureMaxLength); len(got) != defaultCaptureMaxLength {
+		t.Errorf("unexpected length: %d", len(got))
+	}
+}
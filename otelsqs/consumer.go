@@ -0,0 +1,159 @@
+package otelsqs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer for spans started by the carrier.
+const instrumentationName = "github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+
+// MissingAttribute is the span attribute flagging consumer spans for messages
+// that carried no trace context, hence started a new trace.
+const MissingAttribute = "messaging.trace_context.missing"
+
+// SentTimestamp is the message system attribute holding the time the message
+// was sent, in milliseconds since the epoch. Request it with
+// ReceiveMessageInput.MessageSystemAttributeNames in order to record queue time.
+const SentTimestamp = string(types.MessageSystemAttributeNameSentTimestamp)
+
+// QueueTimeAttribute is the span attribute holding how long the message waited
+// in the queue, from SentTimestamp to receive, in milliseconds.
+const QueueTimeAttribute = "messaging.sqs.queue_time_ms"
+
+// StartConsumerSpan extracts the trace context from msg with ExtractMessage and
+// starts a consumer span for processing msg, parented to the producer context.
+// If msg holds the SentTimestamp system attribute, the span records the
+// queue time in QueueTimeAttribute. With WithQueueTimeSpan, a "wait" span
+// covering the queue time is also created under the producer context.
+// If msg carries no trace context, the span is flagged with MissingAttribute,
+// and no "wait" span is created.
+// WithExtractPolicy changes how the extracted context is used: with ExtractLink,
+// the span starts a new trace linked to the producer context, and no "wait" span
+// is created; with ExtractIgnore, the message trace context is not even extracted,
+// and the span starts a new trace as well.
+// With WithMeterProvider, the message is counted as received and its age is
+// recorded; the caller records the processing duration with RecordProcessed.
+// Message attributes allowlisted with WithCapturedAttributes are recorded as span attributes,
+// and, with WithBodyCapture, the redacted body is recorded as a span event.
+// The caller must end the returned span.
+// StartConsumerSpan takes the current time as the receive time; when messages
+// wait before processing, use StartConsumerSpanAt.
+func (c *SqsCarrierAttributes) StartConsumerSpan(ctx context.Context, queueURL string, msg types.Message,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return c.StartConsumerSpanAt(ctx, queueURL, msg, time.Now(), opts...)
+}
+
+// StartConsumerSpanAt is like StartConsumerSpan, for a message received at
+// time received, for instance when ReceiveMessage returned. The queue time and
+// the message age run up to received, so they exclude time spent waiting for a
+// worker.
+func (c *SqsCarrierAttributes) StartConsumerSpanAt(ctx context.Context, queueURL string, msg types.Message,
+	received time.Time, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+
+	ctxProducer := ctx
+	if c.extractPolicy != ExtractIgnore {
+		ctxProducer = c.extractMessage(ctx, msg)
+	}
+
+	missing := c.extractPolicy != ExtractIgnore && !extracted(ctx, ctxProducer)
+
+	if missing {
+		c.recordMissing(ctx, queueURL, c.carrier(msg.MessageAttributes, msg.Attributes, nil),
+			len(msg.MessageAttributes))
+	}
+
+	queue := queueName(queueURL)
+
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemAWSSQS,
+		semconv.MessagingDestinationName(queue),
+		semconv.MessagingMessageID(aws.ToString(msg.MessageId)),
+	}
+
+	sent, found := sentTime(msg)
+	queueTime := max(received.Sub(sent), 0)
+
+	c.recordReceived(ctx, queueURL, queueTime, found)
+
+	if found {
+		attrs = append(attrs, attribute.Int64(QueueTimeAttribute, queueTime.Milliseconds()))
+
+		if c.queueTimeSpan && !missing && c.extractPolicy == ExtractParent {
+			_, wait := c.tracer().Start(ctxProducer, "wait "+queue,
+				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithTimestamp(sent),
+				trace.WithAttributes(attrs...))
+			wait.End(trace.WithTimestamp(sent.Add(queueTime)))
+		}
+	}
+
+	attrs = append(attrs, semconv.MessagingOperationTypeProcess)
+	attrs = append(attrs, c.capturedAttributes(msg.MessageAttributes)...)
+
+	if missing {
+		attrs = append(attrs, attribute.Bool(MissingAttribute, true))
+	}
+
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	}
+
+	ctxParent := ctxProducer
+
+	switch c.extractPolicy {
+	case ExtractLink:
+		ctxParent = ctx
+		options = append(options, trace.WithNewRoot())
+		if !missing {
+			options = append(options, trace.WithLinks(trace.LinkFromContext(ctxProducer)))
+		}
+	case ExtractIgnore:
+		// do not continue a trace already active in ctx either
+		options = append(options, trace.WithNewRoot())
+	}
+
+	ctxSpan, span := c.tracer().Start(ctxParent, "process "+queue, append(options, opts...)...)
+
+	c.recordBody(span, msg.Body)
+
+	return ctxSpan, span
+}
+
+// tracer returns the tracer for spans started by the carrier.
+func (c *SqsCarrierAttributes) tracer() trace.Tracer {
+	provider := c.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(instrumentationName)
+}
+
+// sentTime retrieves the time the message was sent from the SentTimestamp system attribute.
+func sentTime(msg types.Message) (time.Time, bool) {
+	value, found := msg.Attributes[SentTimestamp]
+	if !found {
+		return time.Time{}, false
+	}
+	millis, errConv := strconv.ParseInt(value, 10, 64)
+	if errConv != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
+
+// queueName extracts the queue name from the queue URL.
+// https://sqs.us-east-1.amazonaws.com/123456789012/queue_name -> queue_name
+func queueName(queueURL string) string {
+	return queueURL[strings.LastIndexByte(queueURL, '/')+1:]
+}
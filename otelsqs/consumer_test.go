@@ -0,0 +1,160 @@
+package otelsqs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/orders"
+
+func findAttribute(attrs []attribute.KeyValue, key string) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestStartConsumerSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	sent := time.Now().Add(-3 * time.Second)
+
+	msg := types.Message{
+		MessageId: aws.String("id-1"),
+		Attributes: map[string]string{
+			SentTimestamp: strconv.FormatInt(sent.UnixMilli(), 10),
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {DataType: aws.String("String"),
+				StringValue: aws.String("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		},
+	}
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}),
+		WithTracerProvider(provider), WithQueueTimeSpan(true))
+
+	_, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, msg)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	wait, process := spans[0], spans[1]
+
+	if wait.Name() != "wait orders" {
+		t.Errorf("unexpected wait span name: %s", wait.Name())
+	}
+	if process.Name() != "process orders" {
+		t.Errorf("unexpected process span name: %s", process.Name())
+	}
+	if process.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("unexpected process span kind: %v", process.SpanKind())
+	}
+
+	for _, s := range spans {
+		if s.Parent().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+			s.Parent().SpanID().String() != "00f067aa0ba902b7" {
+			t.Errorf("%s: not parented to producer: %v", s.Name(), s.Parent())
+		}
+	}
+
+	if !wait.StartTime().Equal(time.UnixMilli(sent.UnixMilli())) {
+		t.Errorf("wait span start %v mismatches sent %v", wait.StartTime(), sent)
+	}
+	if wait.EndTime().After(process.StartTime()) {
+		t.Errorf("wait span end %v after process start %v", wait.EndTime(), process.StartTime())
+	}
+
+	queueTime, found := findAttribute(process.Attributes(), QueueTimeAttribute)
+	if !found {
+		t.Fatalf("missing attribute %s", QueueTimeAttribute)
+	}
+	if ms := queueTime.AsInt64(); ms < 3000 || ms > 60000 {
+		t.Errorf("unexpected queue time: %dms", ms)
+	}
+}
+
+func TestStartConsumerSpanAt(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	received := time.Now().Add(-10 * time.Second)
+	sent := received.Add(-3 * time.Second)
+
+	msg := types.Message{
+		Attributes: map[string]string{
+			SentTimestamp: strconv.FormatInt(sent.UnixMilli(), 10),
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {DataType: aws.String("String"),
+				StringValue: aws.String("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		},
+	}
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}),
+		WithTracerProvider(provider), WithQueueTimeSpan(true))
+
+	_, span := carrier.StartConsumerSpanAt(context.TODO(), testQueueURL, msg, received)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	wait, process := spans[0], spans[1]
+
+	if !wait.EndTime().Equal(received) {
+		t.Errorf("wait span end %v mismatches received %v", wait.EndTime(), received)
+	}
+
+	queueTime, found := findAttribute(process.Attributes(), QueueTimeAttribute)
+	if !found {
+		t.Fatalf("missing attribute %s", QueueTimeAttribute)
+	}
+	if ms := queueTime.AsInt64(); ms < 2900 || ms > 3100 {
+		t.Errorf("queue time should exclude the wait for a worker: %dms", ms)
+	}
+}
+
+func TestStartConsumerSpanWithoutSentTimestamp(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithTracerProvider(provider), WithQueueTimeSpan(true))
+
+	_, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, types.Message{})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected only process span, got %d spans", len(spans))
+	}
+	if _, found := findAttribute(spans[0].Attributes(), QueueTimeAttribute); found {
+		t.Errorf("unexpected attribute %s", QueueTimeAttribute)
+	}
+}
+
+func TestQueueName(t *testing.T) {
+	if name := queueName(testQueueURL); name != "orders" {
+		t.Errorf("unexpected queue name: %s", name)
+	}
+	if name := queueName("orders"); name != "orders" {
+		t.Errorf("unexpected queue name: %s", name)
+	}
+}
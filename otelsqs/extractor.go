@@ -0,0 +1,129 @@
+package otelsqs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Extractor extracts trace context from messages arriving from mixed producers,
+// which may carry multiple formats at once, possibly pointing at different traces.
+// Formats are tried in a configurable priority order.
+// Extractor is safe for concurrent use.
+type Extractor struct {
+	formats []Preset
+}
+
+// NewExtractor creates an extractor that tries formats in the given priority order.
+// The first format that yields a valid span context wins.
+//
+// Example:
+//
+//	extractor := otelsqs.NewExtractor(otelsqs.PresetW3C, otelsqs.PresetB3, otelsqs.PresetXRay)
+func NewExtractor(formats ...Preset) *Extractor {
+	return &Extractor{formats: formats}
+}
+
+// FormatAttribute is the link attribute naming the format a losing context was found with.
+const FormatAttribute = attribute.Key("messaging.trace_context.format")
+
+// Extraction reports the outcome of Extractor.Extract.
+type Extraction struct {
+	// Format is the name of the winning format.
+	// It is empty when no format found a valid span context.
+	Format string
+
+	// Losers holds valid span contexts found by lower priority formats
+	// that differ from the winning span context.
+	Losers []Loser
+}
+
+// Loser is a span context found by a format that lost to a higher priority one.
+type Loser struct {
+	Format      string
+	SpanContext trace.SpanContext
+}
+
+// Links returns the losing span contexts as span links, so that no causality
+// is lost when starting the span for the message.
+//
+// Example:
+//
+//	ctx, extraction := extractor.Extract(context.Background(), msg)
+//	ctx, span := tracer.Start(ctx, "handle", trace.WithLinks(extraction.Links()...))
+func (e Extraction) Links() []trace.Link {
+	if len(e.Losers) == 0 {
+		return nil
+	}
+	links := make([]trace.Link, 0, len(e.Losers))
+	for _, l := range e.Losers {
+		links = append(links, trace.Link{
+			SpanContext: l.SpanContext,
+			Attributes:  []attribute.KeyValue{FormatAttribute.String(l.Format)},
+		})
+	}
+	return links
+}
+
+// Extract gets a tracing context from SQS message, trying formats in priority order.
+// The returned context holds the span context found by the winning format.
+// If no format finds a valid span context, ctx is returned unchanged.
+// The message should have been received with the AWSTraceHeader system attribute,
+// if any format uses it.
+func (e *Extractor) Extract(ctx context.Context, msg types.Message) (context.Context, Extraction) {
+	var (
+		result     Extraction
+		winner     context.Context
+		winnerSpan trace.SpanContext
+	)
+
+	// start from a context without span, so that a format that finds
+	// nothing is not mistaken for one that found the caller's span.
+	base := trace.ContextWithSpanContext(ctx, trace.SpanContext{})
+
+	for _, f := range e.formats {
+		carrier := messageCarrier{
+			location:   f.Location,
+			attributes: prefixCarrier{Attributes: msg.MessageAttributes},
+			received:   msg.Attributes,
+		}
+
+		ctxFormat := f.Propagator.Extract(base, carrier)
+
+		sc := trace.SpanContextFromContext(ctxFormat)
+		if !sc.IsValid() {
+			continue
+		}
+
+		if winner == nil {
+			winner = ctxFormat
+			winnerSpan = sc
+			result.Format = f.Name
+			continue
+		}
+
+		if sc.Equal(winnerSpan) || result.hasLoser(sc) {
+			continue
+		}
+
+		result.Losers = append(result.Losers, Loser{Format: f.Name, SpanContext: sc})
+	}
+
+	if winner == nil {
+		return ctx, result
+	}
+
+	return winner, result
+}
+
+// hasLoser reports whether sc was already recorded as loser.
+func (e Extraction) hasLoser(sc trace.SpanContext) bool {
+	for _, l := range e.Losers {
+		if l.SpanContext.Equal(sc) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package otelsqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtractorPrecedence(t *testing.T) {
+
+	const (
+		traceIDW3C  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceIDB3   = "80f198ee56343ba864fe8b2a57d3eff7"
+		traceIDXRay = "5759e988bd862e3fe1be46a994272793"
+	)
+
+	msg := types.Message{
+		Attributes: map[string]string{
+			AWSTraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+			},
+			"b3": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String("80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1"),
+			},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		formats       []Preset
+		expectFormat  string
+		expectTraceID string
+		expectLosers  []string
+	}{
+		{
+			name:          "w3c first",
+			formats:       []Preset{PresetW3C, PresetB3, PresetXRay},
+			expectFormat:  "w3c",
+			expectTraceID: traceIDW3C,
+			expectLosers:  []string{traceIDB3, traceIDXRay},
+		},
+		{
+			name:          "xray first",
+			formats:       []Preset{PresetXRay, PresetB3, PresetW3C},
+			expectFormat:  "xray",
+			expectTraceID: traceIDXRay,
+			expectLosers:  []string{traceIDB3, traceIDW3C},
+		},
+		{
+			name:          "b3 only",
+			formats:       []Preset{PresetB3},
+			expectFormat:  "b3",
+			expectTraceID: traceIDB3,
+		},
+		{
+			name:          "duplicate format",
+			formats:       []Preset{PresetB3, PresetB3},
+			expectFormat:  "b3",
+			expectTraceID: traceIDB3,
+		},
+	}
+
+	for _, data := range testCases {
+		t.Run(data.name, func(t *testing.T) {
+			ctx, extraction := NewExtractor(data.formats...).Extract(context.TODO(), msg)
+
+			if extraction.Format != data.expectFormat {
+				t.Errorf("expected format:%s got format:%s", data.expectFormat, extraction.Format)
+			}
+
+			sc := trace.SpanContextFromContext(ctx)
+			if sc.TraceID().String() != data.expectTraceID {
+				t.Errorf("expected traceID:%s got traceID:%s", data.expectTraceID, sc.TraceID())
+			}
+
+			links := extraction.Links()
+			if len(links) != len(data.expectLosers) {
+				t.Fatalf("expected %d links, got %d", len(data.expectLosers), len(links))
+			}
+			for i, l := range links {
+				if l.SpanContext.TraceID().String() != data.expectLosers[i] {
+					t.Errorf("link %d: expected traceID:%s got traceID:%s", i, data.expectLosers[i], l.SpanContext.TraceID())
+				}
+				if len(l.Attributes) != 1 || l.Attributes[0].Key != FormatAttribute {
+					t.Errorf("link %d: missing attribute %s: %v", i, FormatAttribute, l.Attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractorNoContext(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	})
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), sc)
+
+	msg := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{},
+	}
+
+	ctxNew, extraction := NewExtractor(PresetW3C, PresetB3, PresetXRay).Extract(ctx, msg)
+
+	if extraction.Format != "" {
+		t.Errorf("unexpected format: %s", extraction.Format)
+	}
+
+	if links := extraction.Links(); links != nil {
+		t.Errorf("unexpected links: %v", links)
+	}
+
+	if !trace.SpanContextFromContext(ctxNew).Equal(sc) {
+		t.Errorf("expected context unchanged")
+	}
+}
@@ -0,0 +1,255 @@
+package otelsqs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelcarrier"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AWSTraceHeader is the message system attribute holding the X-Ray trace header.
+// Request it with ReceiveMessageInput.MessageSystemAttributeNames in order to
+// extract trace context with presets that use LocationSystemAttribute or LocationBoth.
+const AWSTraceHeader = string(types.MessageSystemAttributeNameAWSTraceHeader)
+
+// xrayHeader is the field used by the X-Ray propagator.
+const xrayHeader = "X-Amzn-Trace-Id"
+
+// messageCarrier adapts an SQS message to propagation.TextMapCarrier.
+// According to location, the X-Ray header is mapped to the AWSTraceHeader
+// system attribute, and other fields to message attributes named with prefix.
+type messageCarrier struct {
+	location   Location
+	attributes prefixCarrier
+	received   map[string]string                            // system attributes of received message
+	sent       map[string]types.MessageSystemAttributeValue // system attributes of message to send
+}
+
+// system reports whether key is kept in the AWSTraceHeader system attribute.
+func (c messageCarrier) system(key string) bool {
+	return c.location != LocationMessageAttributes && strings.EqualFold(key, xrayHeader)
+}
+
+// Get returns the value for the key.
+func (c messageCarrier) Get(key string) string {
+	if c.system(key) {
+		if c.received != nil {
+			return c.received[AWSTraceHeader]
+		}
+		return aws.ToString(c.sent[AWSTraceHeader].StringValue)
+	}
+	if c.location == LocationSystemAttribute {
+		return ""
+	}
+	return c.attributes.Get(key)
+}
+
+// Set stores a key-value pair.
+func (c messageCarrier) Set(key, value string) {
+	if c.system(key) {
+		if c.sent != nil {
+			c.sent[AWSTraceHeader] = types.MessageSystemAttributeValue{
+				DataType:    aws.String(stringType),
+				StringValue: aws.String(value),
+			}
+		}
+		return
+	}
+	if c.location == LocationSystemAttribute {
+		return
+	}
+	c.attributes.Set(key, value)
+}
+
+// Keys lists the keys in the carrier.
+func (c messageCarrier) Keys() []string {
+	var keys []string
+	if c.location != LocationSystemAttribute {
+		keys = c.attributes.Keys()
+	}
+	if c.location != LocationMessageAttributes && c.Get(xrayHeader) != "" {
+		keys = append(keys, xrayHeader)
+	}
+	return keys
+}
+
+// carrier adapts message attributes, along with system attributes of either a
+// received or a sent message, according to the carrier configuration.
+func (c *SqsCarrierAttributes) carrier(messageAttributes map[string]types.MessageAttributeValue,
+	received map[string]string, sent map[string]types.MessageSystemAttributeValue) propagation.TextMapCarrier {
+
+	if c.location == LocationMessageAttributes && c.keyPrefix == "" &&
+		(c.dataType == "" || c.dataType == stringType) {
+		return MessageAttributesCarrier(messageAttributes) // no allocation
+	}
+
+	return messageCarrier{
+		location: c.location,
+		attributes: prefixCarrier{
+			Attributes: messageAttributes,
+			Prefix:     c.keyPrefix,
+			DataType:   c.dataType,
+		},
+		received: received,
+		sent:     sent,
+	}
+}
+
+// inject inserts tracing from context into message attributes and,
+// if sent is not nil, into system attributes.
+// With validation enabled, attributes are staged and only written when valid.
+func (c *SqsCarrierAttributes) inject(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue,
+	sent map[string]types.MessageSystemAttributeValue) error {
+
+	propagator := c.getPropagator(ctx)
+
+	if !c.validate {
+		propagator.Inject(ctx, c.carrier(messageAttributes, nil, sent))
+		return nil
+	}
+
+	stagedAttributes := map[string]types.MessageAttributeValue{}
+	var stagedSent map[string]types.MessageSystemAttributeValue
+	if sent != nil {
+		stagedSent = map[string]types.MessageSystemAttributeValue{}
+	}
+
+	propagator.Inject(ctx, c.carrier(stagedAttributes, nil, stagedSent))
+
+	errMerge := otelcarrier.Merge(messageAttributes, stagedAttributes, c.attributeLimit(),
+		validateAttribute, ErrMaxAttrLimit)
+	if errMerge != nil {
+		return errMerge
+	}
+
+	maps.Copy(sent, stagedSent)
+
+	return nil
+}
+
+// ExtractMessage gets a tracing context from SQS message.
+// Unlike Extract, it honors the carrier location, looking up the AWSTraceHeader
+// system attribute when required by the preset. Hence the message should have
+// been received with AWSTraceHeader among the requested system attributes.
+// With WithBodyFallback, it also looks into the SNS notification envelope in the body.
+// With WithMeterProvider, a message carrying no trace context is counted in
+// MetricTraceContextMissing, without a destination since msg does not hold the queue.
+// Use ExtractMessage right after receiving an SQS message.
+func (c *SqsCarrierAttributes) ExtractMessage(ctx context.Context, msg types.Message) context.Context {
+	ctxNew := c.extractMessage(ctx, msg)
+	if !extracted(ctx, ctxNew) {
+		c.recordMissing(ctx, "", c.carrier(msg.MessageAttributes, msg.Attributes, nil),
+			len(msg.MessageAttributes))
+	}
+	return ctxNew
+}
+
+// extractMessage is ExtractMessage without metrics.
+func (c *SqsCarrierAttributes) extractMessage(ctx context.Context, msg types.Message) context.Context {
+	propagator := c.getPropagator(ctx)
+
+	ctxNew := propagator.Extract(ctx, c.carrier(msg.MessageAttributes, msg.Attributes, nil))
+
+	if !c.bodyFallback || extracted(ctx, ctxNew) {
+		return ctxNew
+	}
+
+	attributes, found := notificationAttributes(aws.ToString(msg.Body))
+	if !found {
+		return ctxNew
+	}
+
+	return propagator.Extract(ctx, c.carrier(attributes, nil, nil))
+}
+
+// extracted reports whether extraction found a span context missing from ctx.
+func extracted(ctx, ctxNew context.Context) bool {
+	sc := trace.SpanContextFromContext(ctxNew)
+	return sc.IsValid() && !sc.Equal(trace.SpanContextFromContext(ctx))
+}
+
+// snsNotification is the JSON envelope wrapping the body of messages
+// delivered from SNS to SQS without raw message delivery.
+type snsNotification struct {
+	Type              string                              `json:"Type"`
+	MessageAttributes map[string]snsNotificationAttribute `json:"MessageAttributes"`
+}
+
+type snsNotificationAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// notificationAttributes retrieves message attributes from the SNS notification envelope in body.
+func notificationAttributes(body string) (map[string]types.MessageAttributeValue, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return nil, false
+	}
+
+	var n snsNotification
+	if errJSON := json.Unmarshal([]byte(body), &n); errJSON != nil {
+		return nil, false
+	}
+	if n.Type != "Notification" || len(n.MessageAttributes) == 0 {
+		return nil, false
+	}
+
+	attributes := make(map[string]types.MessageAttributeValue, len(n.MessageAttributes))
+	for name, attr := range n.MessageAttributes {
+		if strings.HasPrefix(attr.Type, binaryType) {
+			value, errBase64 := base64.StdEncoding.DecodeString(attr.Value)
+			if errBase64 != nil {
+				continue
+			}
+			attributes[name] = types.MessageAttributeValue{
+				DataType:    aws.String(attr.Type),
+				BinaryValue: value,
+			}
+			continue
+		}
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String(attr.Type),
+			StringValue: aws.String(attr.Value),
+		}
+	}
+
+	return attributes, true
+}
+
+// InjectInput inserts tracing from context into the SQS send input.
+// Unlike Inject, it honors the carrier location, writing the AWSTraceHeader
+// system attribute when required by the preset. Nil attribute maps in input are created.
+// If input.MessageAttributes holds 10 or more items, InjectInput will do nothing and
+// return ErrMaxAttrLimit, unless the carrier location is LocationSystemAttribute.
+// The limit can be changed with WithMaxAttributes.
+// Use InjectInput right before sending out the SQS message.
+func (c *SqsCarrierAttributes) InjectInput(ctx context.Context, input *sqs.SendMessageInput) error {
+	err := c.injectInput(ctx, input)
+	if err != nil {
+		c.recordFailure(ctx, "inject", aws.ToString(input.QueueUrl), errorReason(err))
+	}
+	return err
+}
+
+func (c *SqsCarrierAttributes) injectInput(ctx context.Context, input *sqs.SendMessageInput) error {
+	if c.location != LocationSystemAttribute {
+		if input.MessageAttributes == nil {
+			input.MessageAttributes = make(map[string]types.MessageAttributeValue)
+		}
+		if len(input.MessageAttributes) >= c.attributeLimit() {
+			return ErrMaxAttrLimit
+		}
+	}
+	if c.location != LocationMessageAttributes && input.MessageSystemAttributes == nil {
+		input.MessageSystemAttributes = make(map[string]types.MessageSystemAttributeValue)
+	}
+	return c.inject(ctx, input.MessageAttributes, input.MessageSystemAttributes)
+}
@@ -0,0 +1,333 @@
+package otelsqs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/semconv/v1.40.0/messagingconv"
+)
+
+// Metric names not covered by messaging semantic conventions.
+const (
+	// MetricMessageAge is the histogram of time messages waited in the queue,
+	// from SentTimestamp to receive, in seconds.
+	MetricMessageAge = "messaging.sqs.message.age"
+
+	// MetricDeletedMessages counts messages deleted from the queue.
+	MetricDeletedMessages = "messaging.sqs.client.deleted.messages"
+
+	// MetricTraceContextMissing counts received messages carrying no trace context,
+	// by queue, by reason and by trace keys present, see MissingReasonAttribute
+	// and MissingKeysAttribute.
+	MetricTraceContextMissing = "messaging.sqs.trace_context.missing"
+
+	// MetricTraceContextFailures counts failures to inject or extract trace context,
+	// by operation and reason.
+	MetricTraceContextFailures = "messaging.sqs.trace_context.failures"
+
+	// MetricBackoff is the histogram of delays taken before retrying SQS calls,
+	// by operation and error type, in seconds.
+	MetricBackoff = "messaging.sqs.client.backoff.duration"
+)
+
+// Failure reasons reported by MetricTraceContextFailures with attribute error.type.
+const (
+	ReasonMaxAttributeLimit = "max_attribute_limit"
+	ReasonInvalidAttribute  = "invalid_attribute"
+	ReasonNilAttributes     = "nil_attributes"
+	ReasonMalformed         = "malformed"
+)
+
+// carrierMetrics holds instruments for a carrier created with WithMeterProvider.
+type carrierMetrics struct {
+	sent     messagingconv.ClientSentMessages
+	consumed messagingconv.ClientConsumedMessages
+	process  messagingconv.ProcessDuration
+	age      metric.Float64Histogram
+	deleted  metric.Int64Counter
+	failures metric.Int64Counter
+	missing  metric.Int64Counter
+	backoff  metric.Float64Histogram
+}
+
+// newCarrierMetrics creates instruments from provider.
+// Instrument creation errors are reported to the global error handler.
+func newCarrierMetrics(provider metric.MeterProvider) *carrierMetrics {
+	meter := provider.Meter(instrumentationName)
+
+	var m carrierMetrics
+	var err, errs error
+
+	m.sent, err = messagingconv.NewClientSentMessages(meter)
+	errs = errors.Join(errs, err)
+
+	m.consumed, err = messagingconv.NewClientConsumedMessages(meter)
+	errs = errors.Join(errs, err)
+
+	m.process, err = messagingconv.NewProcessDuration(meter)
+	errs = errors.Join(errs, err)
+
+	m.age, err = meter.Float64Histogram(MetricMessageAge,
+		metric.WithDescription("Time messages waited in the queue, from SentTimestamp to receive."),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+
+	m.deleted, err = meter.Int64Counter(MetricDeletedMessages,
+		metric.WithDescription("Number of messages deleted from the queue."),
+		metric.WithUnit("{message}"))
+	errs = errors.Join(errs, err)
+
+	m.failures, err = meter.Int64Counter(MetricTraceContextFailures,
+		metric.WithDescription("Number of failures to inject or extract trace context."),
+		metric.WithUnit("{failure}"))
+	errs = errors.Join(errs, err)
+
+	m.missing, err = meter.Int64Counter(MetricTraceContextMissing,
+		metric.WithDescription("Number of received messages carrying no trace context."),
+		metric.WithUnit("{message}"))
+	errs = errors.Join(errs, err)
+
+	m.backoff, err = meter.Float64Histogram(MetricBackoff,
+		metric.WithDescription("Delay taken before retrying SQS calls."),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+
+	if errs != nil {
+		otel.Handle(errs)
+	}
+
+	return &m
+}
+
+// destinationAttributes returns the attributes identifying the queue.
+func destinationAttributes(queueURL string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemAWSSQS,
+		destinationName(queueURL),
+	}
+}
+
+// destinationName returns the queue name attribute, for instruments
+// from messagingconv that already set the messaging system.
+func destinationName(queueURL string) attribute.KeyValue {
+	return semconv.MessagingDestinationName(queueName(queueURL))
+}
+
+// errorReason maps inject errors to failure reasons.
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMaxAttrLimit):
+		return ReasonMaxAttributeLimit
+	case errors.Is(err, ErrInvalidAttribute):
+		return ReasonInvalidAttribute
+	case errors.Is(err, ErrMessageAttributesIsNil):
+		return ReasonNilAttributes
+	}
+	return semconv.ErrorTypeOther.Value.AsString()
+}
+
+// recordFailure counts a failure to inject or extract trace context.
+func (c *SqsCarrierAttributes) recordFailure(ctx context.Context, operation, queueURL, reason string) {
+	if c.metrics == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.MessagingOperationName(operation),
+		semconv.ErrorTypeKey.String(reason),
+	}
+	if queueURL != "" {
+		attrs = append(attrs, destinationAttributes(queueURL)...)
+	}
+	c.metrics.failures.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// MissingReasonAttribute is the metric attribute telling why a message
+// counted by MetricTraceContextMissing carried no trace context.
+const MissingReasonAttribute = "messaging.sqs.trace_context.missing_reason"
+
+// Reasons reported by MetricTraceContextMissing with attribute MissingReasonAttribute.
+const (
+	// MissingNoAttributes flags messages without message attributes.
+	MissingNoAttributes = "no_attributes"
+
+	// MissingNoTraceFields flags messages with message attributes,
+	// none of them written by the propagator.
+	MissingNoTraceFields = "no_trace_fields"
+
+	// MissingMalformed flags messages with propagator fields that failed to parse.
+	MissingMalformed = ReasonMalformed
+)
+
+// MissingKeysAttribute is the metric attribute listing the trace keys present
+// in a message counted by MetricTraceContextMissing, sorted, in lower case and
+// separated by commas, for instance "x-b3-sampled,x-b3-spanid,x-b3-traceid".
+// It is empty when no trace key is present.
+// Only the propagator fields and the keys of well-known formats (W3C, B3, Jaeger,
+// X-Ray, OpenTracing and Datadog) are listed, so that the attribute has bounded cardinality.
+const MissingKeysAttribute = "messaging.sqs.trace_context.keys"
+
+// knownTraceKeys are the keys of well-known trace context formats reported by
+// MissingKeysAttribute, besides the fields of the carrier propagator.
+var knownTraceKeys = []string{
+	// W3C
+	"traceparent", "tracestate", "baggage",
+	// B3
+	"b3", "x-b3-traceid", "x-b3-spanid", "x-b3-parentspanid", "x-b3-sampled", "x-b3-flags",
+	// Jaeger
+	"uber-trace-id",
+	// X-Ray
+	"x-amzn-trace-id",
+	// OpenTracing
+	"ot-tracer-traceid", "ot-tracer-spanid", "ot-tracer-sampled",
+	// Datadog
+	"_datadog", "x-datadog-trace-id", "x-datadog-parent-id", "x-datadog-sampling-priority",
+}
+
+// recordMissing counts a received message carrying no trace context. carrier
+// holds the message attributes, of which there are attributeCount.
+// Without queueURL, the message is counted without a destination.
+func (c *SqsCarrierAttributes) recordMissing(ctx context.Context, queueURL string,
+	carrier propagation.TextMapCarrier, attributeCount int) {
+	if c.metrics == nil {
+		return
+	}
+
+	reason := MissingNoAttributes
+	switch {
+	case len(c.fieldsFound(ctx, carrier)) > 0:
+		reason = MissingMalformed
+		c.recordFailure(ctx, "extract", queueURL, ReasonMalformed)
+	case attributeCount > 0:
+		reason = MissingNoTraceFields
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String(MissingReasonAttribute, reason),
+		attribute.String(MissingKeysAttribute, strings.Join(c.traceKeys(ctx, carrier), ",")),
+	}
+	if queueURL != "" {
+		attrs = append(attrs, destinationAttributes(queueURL)...)
+	}
+	c.metrics.missing.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// fieldsFound lists the propagator fields present in carrier.
+func (c *SqsCarrierAttributes) fieldsFound(ctx context.Context, carrier propagation.TextMapCarrier) []string {
+	var found []string
+	for _, field := range c.getPropagator(ctx).Fields() {
+		if carrier.Get(field) != "" {
+			found = append(found, field)
+		}
+	}
+	return found
+}
+
+// traceKeys lists the keys in carrier that are either propagator fields
+// or knownTraceKeys, sorted and in lower case.
+func (c *SqsCarrierAttributes) traceKeys(ctx context.Context, carrier propagation.TextMapCarrier) []string {
+	known := map[string]bool{}
+	for _, key := range knownTraceKeys {
+		known[key] = true
+	}
+	for _, field := range c.getPropagator(ctx).Fields() {
+		known[strings.ToLower(field)] = true
+	}
+
+	var keys []string
+	for _, key := range carrier.Keys() {
+		key = strings.ToLower(key)
+		if known[key] && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// recordReceived counts a received message and records its age.
+func (c *SqsCarrierAttributes) recordReceived(ctx context.Context, queueURL string, age time.Duration, hasAge bool) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.consumed.Add(ctx, 1, "receive", messagingconv.SystemAWSSQS, destinationName(queueURL))
+	if hasAge {
+		c.metrics.age.Record(ctx, age.Seconds(), metric.WithAttributes(destinationAttributes(queueURL)...))
+	}
+}
+
+// RecordSent counts count messages sent to queueURL.
+// Pass the error returned by SendMessage or SendMessageBatch, if any.
+// RecordSent does nothing unless the carrier was created with WithMeterProvider.
+func (c *SqsCarrierAttributes) RecordSent(ctx context.Context, queueURL string, count int, err error) {
+	if c.metrics == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{destinationName(queueURL)}
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeOther)
+	}
+	c.metrics.sent.Add(ctx, int64(count), "send", messagingconv.SystemAWSSQS, attrs...)
+}
+
+// RecordDeleted counts count messages deleted from queueURL.
+// Pass the error returned by DeleteMessage or DeleteMessageBatch, if any.
+// RecordDeleted does nothing unless the carrier was created with WithMeterProvider.
+func (c *SqsCarrierAttributes) RecordDeleted(ctx context.Context, queueURL string, count int, err error) {
+	if c.metrics == nil {
+		return
+	}
+	attrs := destinationAttributes(queueURL)
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeOther)
+	}
+	c.metrics.deleted.Add(ctx, int64(count), metric.WithAttributes(attrs...))
+}
+
+// RecordBackoff records a delay taken before retrying operation on queueURL,
+// such as "receive" or "delete". Pass the error causing the backoff, which is
+// reported as error.type: the SQS error code for API errors, such as a
+// throttling error, or else _OTHER. Pass nil err for backoffs not caused by
+// errors, such as empty receives from endpoints ignoring long polling.
+// RecordBackoff does nothing unless the carrier was created with WithMeterProvider.
+func (c *SqsCarrierAttributes) RecordBackoff(ctx context.Context, queueURL, operation string,
+	delay time.Duration, err error) {
+	if c.metrics == nil {
+		return
+	}
+	attrs := append(destinationAttributes(queueURL), semconv.MessagingOperationName(operation))
+	if err != nil {
+		errorType := semconv.ErrorTypeOther.Value.AsString()
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			errorType = apiErr.ErrorCode()
+		}
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType))
+	}
+	c.metrics.backoff.Record(ctx, delay.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// RecordProcessed records the duration of processing a message from queueURL.
+// Pass the error the processing failed with, if any, reported as error.type _OTHER.
+// Record it once the message is handled, before settling it, so that the
+// duration excludes deleting the message.
+// RecordProcessed does nothing unless the carrier was created with WithMeterProvider.
+func (c *SqsCarrierAttributes) RecordProcessed(ctx context.Context, queueURL string,
+	duration time.Duration, err error) {
+	if c.metrics == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{destinationName(queueURL)}
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeOther)
+	}
+	c.metrics.process.Record(ctx, duration.Seconds(), "process", messagingconv.SystemAWSSQS, attrs...)
+}
@@ -0,0 +1,344 @@
+package otelsqs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// collect reads all metrics from reader, by name.
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.TODO(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+// sumFor returns the sum of data points of an int64 counter matching attribute key=value.
+func sumFor(t *testing.T, m metricdata.Metrics, key, value string) int64 {
+	t.Helper()
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s: unexpected data type: %T", m.Name, m.Data)
+	}
+	var total int64
+	for _, dp := range sum.DataPoints {
+		if v, found := dp.Attributes.Value(attribute.Key(key)); found && v.AsString() == value {
+			total += dp.Value
+		}
+	}
+	return total
+}
+
+// histogramCount returns the number of recordings of a float64 histogram matching attribute key=value.
+func histogramCount(t *testing.T, m metricdata.Metrics, key, value string) uint64 {
+	t.Helper()
+	hist, ok := m.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("%s: unexpected data type: %T", m.Name, m.Data)
+	}
+	var count uint64
+	for _, dp := range hist.DataPoints {
+		if v, found := dp.Attributes.Value(attribute.Key(key)); found && v.AsString() == value {
+			count += dp.Count
+		}
+	}
+	return count
+}
+
+func newTestMeter() (*sdkmetric.ManualReader, *sdkmetric.MeterProvider) {
+	reader := sdkmetric.NewManualReader()
+	return reader, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+}
+
+func TestMetricsConsumer(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMeterProvider(provider))
+
+	sent := time.Now().Add(-2 * time.Second)
+
+	msg := types.Message{
+		Attributes: map[string]string{
+			SentTimestamp: strconv.FormatInt(sent.UnixMilli(), 10),
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {DataType: aws.String("String"), StringValue: aws.String("garbage")},
+		},
+	}
+
+	ctx, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, msg)
+	carrier.RecordProcessed(ctx, testQueueURL, time.Second, errors.New("failed"))
+	span.End()
+
+	metrics := collect(t, reader)
+
+	if got := sumFor(t, metrics["messaging.client.consumed.messages"], "messaging.destination.name", "orders"); got != 1 {
+		t.Errorf("consumed messages: expected 1, got %d", got)
+	}
+
+	age := metrics[MetricMessageAge]
+	if got := histogramCount(t, age, "messaging.destination.name", "orders"); got != 1 {
+		t.Errorf("message age: expected 1 recording, got %d", got)
+	}
+	if hist := age.Data.(metricdata.Histogram[float64]); len(hist.DataPoints) == 1 {
+		if sum := hist.DataPoints[0].Sum; sum < 2 {
+			t.Errorf("message age: expected at least 2s, got %v", sum)
+		}
+	}
+
+	if got := histogramCount(t, metrics["messaging.process.duration"], "error.type", "_OTHER"); got != 1 {
+		t.Errorf("process duration: expected 1 failed recording, got %d", got)
+	}
+
+	if got := sumFor(t, metrics[MetricTraceContextFailures], "error.type", ReasonMalformed); got != 1 {
+		t.Errorf("extract failures: expected 1, got %d", got)
+	}
+
+	if got := sumFor(t, metrics[MetricTraceContextMissing], MissingReasonAttribute, MissingMalformed); got != 1 {
+		t.Errorf("missing trace context malformed: expected 1, got %d", got)
+	}
+}
+
+func TestMetricsMissingTraceKeys(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMeterProvider(provider))
+
+	stringAttr := func(value string) types.MessageAttributeValue {
+		return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+
+	// producer still on B3 multi header
+	b3Message := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"X-B3-TraceId": stringAttr("4bf92f3577b34da6a3ce929d0e0e4736"),
+			"X-B3-SpanId":  stringAttr("00f067aa0ba902b7"),
+			"tenant":       stringAttr("a"),
+		},
+	}
+	carrier.ExtractMessage(context.TODO(), b3Message)
+
+	// producer on Jaeger, extracted from attributes only
+	carrier.Extract(context.TODO(), map[string]types.MessageAttributeValue{
+		"uber-trace-id": stringAttr("4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1"),
+	})
+
+	// producer writing a broken traceparent
+	carrier.ExtractMessage(context.TODO(), types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": stringAttr("garbage"),
+		},
+	})
+
+	// traced message is not counted
+	carrier.ExtractMessage(context.TODO(), types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": stringAttr("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+		},
+	})
+
+	metrics := collect(t, reader)
+	missing := metrics[MetricTraceContextMissing]
+
+	table := []struct {
+		keys     string
+		reason   string
+		expected int64
+	}{
+		{"x-b3-spanid,x-b3-traceid", MissingNoTraceFields, 1},
+		{"uber-trace-id", MissingNoTraceFields, 1},
+		{"traceparent", MissingMalformed, 1},
+	}
+
+	for _, data := range table {
+		if got := sumFor(t, missing, MissingKeysAttribute, data.keys); got != data.expected {
+			t.Errorf("keys %q: expected %d, got %d", data.keys, data.expected, got)
+		}
+		if got := sumFor(t, missing, MissingReasonAttribute, data.reason); got < data.expected {
+			t.Errorf("keys %q: reason %s: expected at least %d, got %d", data.keys, data.reason, data.expected, got)
+		}
+	}
+
+	// queue is unknown to Extract and ExtractMessage
+	if got := sumFor(t, missing, "messaging.destination.name", "orders"); got != 0 {
+		t.Errorf("expected no destination, got %d", got)
+	}
+
+	if got := sumFor(t, metrics[MetricTraceContextFailures], "error.type", ReasonMalformed); got != 1 {
+		t.Errorf("extract failures: expected 1, got %d", got)
+	}
+}
+
+func TestMetricsInjectFailures(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMeterProvider(provider),
+		WithMaxAttributes(1))
+
+	ctx, _ := testContext(t)
+
+	if err := carrier.Inject(ctx, nil); !errors.Is(err, ErrMessageAttributesIsNil) {
+		t.Errorf("expected ErrMessageAttributesIsNil, got: %v", err)
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+		},
+	}
+	if err := carrier.InjectInput(ctx, input); !errors.Is(err, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", err)
+	}
+
+	failures := collect(t, reader)[MetricTraceContextFailures]
+
+	if got := sumFor(t, failures, "error.type", ReasonNilAttributes); got != 1 {
+		t.Errorf("nil attributes: expected 1, got %d", got)
+	}
+	if got := sumFor(t, failures, "messaging.destination.name", "orders"); got != 1 {
+		t.Errorf("max attribute limit on orders: expected 1, got %d", got)
+	}
+}
+
+func TestMetricsSentDeleted(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	carrier := NewCarrier(WithMeterProvider(provider))
+
+	carrier.RecordSent(context.TODO(), testQueueURL, 3, nil)
+	carrier.RecordSent(context.TODO(), testQueueURL, 1, errors.New("send failed"))
+	carrier.RecordDeleted(context.TODO(), testQueueURL, 2, nil)
+
+	metrics := collect(t, reader)
+
+	if got := sumFor(t, metrics["messaging.client.sent.messages"], "messaging.destination.name", "orders"); got != 4 {
+		t.Errorf("sent messages: expected 4, got %d", got)
+	}
+	if got := sumFor(t, metrics["messaging.client.sent.messages"], "error.type", "_OTHER"); got != 1 {
+		t.Errorf("failed sent messages: expected 1, got %d", got)
+	}
+	if got := sumFor(t, metrics[MetricDeletedMessages], "messaging.system", "aws_sqs"); got != 2 {
+		t.Errorf("deleted messages: expected 2, got %d", got)
+	}
+}
+
+func TestMetricsBackoff(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	carrier := NewCarrier(WithMeterProvider(provider))
+
+	throttled := &smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"}
+
+	carrier.RecordBackoff(context.TODO(), testQueueURL, "receive", time.Second, throttled)
+	carrier.RecordBackoff(context.TODO(), testQueueURL, "receive", time.Second, errors.New("network"))
+	carrier.RecordBackoff(context.TODO(), testQueueURL, "delete", time.Second, nil)
+
+	metrics := collect(t, reader)
+
+	if got := histogramCount(t, metrics[MetricBackoff], "messaging.operation.name", "receive"); got != 2 {
+		t.Errorf("receive backoffs: expected 2, got %d", got)
+	}
+	if got := histogramCount(t, metrics[MetricBackoff], "error.type", "RequestThrottled"); got != 1 {
+		t.Errorf("throttled backoffs: expected 1, got %d", got)
+	}
+	if got := histogramCount(t, metrics[MetricBackoff], "error.type", "_OTHER"); got != 1 {
+		t.Errorf("other backoffs: expected 1, got %d", got)
+	}
+	if got := histogramCount(t, metrics[MetricBackoff], "messaging.operation.name", "delete"); got != 1 {
+		t.Errorf("delete backoffs: expected 1, got %d", got)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	// without WithMeterProvider, recording is a no-op
+	carrier := NewCarrier()
+	carrier.RecordSent(context.TODO(), testQueueURL, 1, nil)
+	carrier.RecordDeleted(context.TODO(), testQueueURL, 1, nil)
+	carrier.RecordProcessed(context.TODO(), testQueueURL, time.Second, nil)
+	_, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, types.Message{})
+	span.End()
+}
+
+func TestMetricsMissingTraceContext(t *testing.T) {
+	reader, provider := newTestMeter()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMeterProvider(provider),
+		WithTracerProvider(tracerProvider), WithQueueTimeSpan(true))
+
+	untraced := types.Message{
+		Attributes: map[string]string{
+			SentTimestamp: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant":   {DataType: aws.String("String"), StringValue: aws.String("a")},
+			"producer": {DataType: aws.String("String"), StringValue: aws.String("legacy")},
+		},
+	}
+
+	traced := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {DataType: aws.String("String"),
+				StringValue: aws.String("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		},
+	}
+
+	for _, msg := range []types.Message{untraced, traced, untraced, {}} {
+		_, span := carrier.StartConsumerSpan(context.TODO(), testQueueURL, msg)
+		span.End()
+	}
+
+	missing := collect(t, reader)[MetricTraceContextMissing]
+
+	if got := sumFor(t, missing, "messaging.destination.name", "orders"); got != 3 {
+		t.Errorf("missing trace context: expected 3, got %d", got)
+	}
+	if got := sumFor(t, missing, MissingReasonAttribute, MissingNoTraceFields); got != 2 {
+		t.Errorf("missing trace context without trace fields: expected 2, got %d", got)
+	}
+	if got := sumFor(t, missing, MissingReasonAttribute, MissingNoAttributes); got != 1 {
+		t.Errorf("missing trace context without attributes: expected 1, got %d", got)
+	}
+	if got := sumFor(t, missing, MissingKeysAttribute, ""); got != 3 {
+		t.Errorf("missing trace context without trace keys: expected 3, got %d", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 process spans without wait spans for missing context, got %d", len(spans))
+	}
+	for i, s := range spans {
+		_, flagged := findAttribute(s.Attributes(), MissingAttribute)
+		if expected := i != 1; flagged != expected {
+			t.Errorf("span %d: %s flagged=%t expected=%t", i, MissingAttribute, flagged, expected)
+		}
+		if flagged && s.Parent().IsValid() {
+			t.Errorf("span %d: expected root span", i)
+		}
+	}
+}
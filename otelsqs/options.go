@@ -0,0 +1,165 @@
+package otelsqs
+
+import (
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a carrier created with NewCarrier.
+type Option func(c *SqsCarrierAttributes)
+
+// WithPropagator sets propagator for carrier.
+// If unspecified, carrier uses the propagator from context set with ContextWithPropagator,
+// or else the default propagator defined with SetTextMapPropagator.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.WithPropagator(propagator)
+	}
+}
+
+// WithPreset sets propagator, location and data type for carrier from preset.
+func WithPreset(preset Preset) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.WithPreset(preset)
+	}
+}
+
+// WithKeyPrefix prepends prefix to the message attribute names read and written
+// by the carrier, for instance "otel." turns "traceparent" into "otel.traceparent".
+// The AWSTraceHeader system attribute is not affected.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.keyPrefix = prefix
+	}
+}
+
+// WithMaxAttributes sets the maximum number of message attributes.
+// Inject refuses messages already holding limit attributes or more.
+// Defaults to 10, the SQS limit.
+func WithMaxAttributes(limit int) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.maxAttributes = limit
+	}
+}
+
+// WithDataType sets the data type for message attributes written by the carrier,
+// for instance "String" or "Binary". Custom types such as "String.trace" are accepted.
+// Defaults to "String".
+func WithDataType(dataType string) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.dataType = dataType
+	}
+}
+
+// WithBodyFallback enables ExtractMessage to look for trace context within the message
+// body, when message attributes carry none. This covers messages delivered from SNS
+// without raw message delivery, whose attributes are wrapped in the body as a JSON
+// notification envelope.
+func WithBodyFallback(enable bool) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.bodyFallback = enable
+	}
+}
+
+// WithValidation enables Inject and InjectInput to check attributes written by the
+// propagator against SQS rules for attribute names, values and count, returning
+// ErrInvalidAttribute or ErrMaxAttrLimit instead of producing a message that SQS would refuse.
+func WithValidation(enable bool) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.validate = enable
+	}
+}
+
+// WithTracerProvider sets the tracer provider for spans started by the carrier.
+// Defaults to the global tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithQueueTimeSpan enables StartConsumerSpan to create a "wait" span covering the
+// time the message waited in the queue, from SentTimestamp to receive, parented
+// to the producer context.
+func WithQueueTimeSpan(enable bool) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.queueTimeSpan = enable
+	}
+}
+
+// WithMeterProvider enables metrics recorded through provider: message age,
+// processing duration, sent, received and deleted messages, and failures to inject
+// or extract trace context. Without WithMeterProvider, no metrics are recorded.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.metrics = newCarrierMetrics(provider)
+	}
+}
+
+// WithExtractPolicy sets how StartConsumerSpan uses the trace context extracted
+// from messages. Defaults to ExtractParent.
+func WithExtractPolicy(policy ExtractPolicy) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.extractPolicy = policy
+	}
+}
+
+// WithCapturedAttributes sets an allowlist of message attribute names that
+// StartConsumerSpan and StartProducerSpan record as span attributes named
+// MessageAttributePrefix+name, such as "messaging.sqs.message_attribute.tenant".
+// Values are truncated, see WithCapturedAttributeMaxLength.
+func WithCapturedAttributes(names ...string) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.captureNames = append(c.captureNames, names...)
+	}
+}
+
+// WithHashedAttributes captures the named message attributes, as WithCapturedAttributes,
+// but records the hex HMAC-SHA-256 of their values under key, for sensitive values
+// that must be searchable but not readable. Unlike a plain hash, the keyed hash
+// cannot be reversed by hashing guessed values without the key, so keep key secret,
+// and share it only with whoever searches traces. All hashed attributes use the key
+// of the last WithHashedAttributes. An empty key disables the option.
+func WithHashedAttributes(key []byte, names ...string) Option {
+	return func(c *SqsCarrierAttributes) {
+		if len(key) == 0 {
+			return
+		}
+		c.captureHashKey = key
+		if c.captureHash == nil {
+			c.captureHash = map[string]bool{}
+		}
+		for _, name := range names {
+			if !c.captureHash[name] {
+				c.captureHash[name] = true
+				c.captureNames = append(c.captureNames, name)
+			}
+		}
+	}
+}
+
+// WithCapturedAttributeMaxLength sets the maximum length in bytes of captured
+// attribute values. Longer values are truncated. Defaults to 128.
+func WithCapturedAttributeMaxLength(maxLength int) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.captureMaxLength = maxLength
+	}
+}
+
+// WithBodyCapture enables StartConsumerSpan and StartProducerSpan to record the
+// message body as the span event BodyEvent, after redaction and truncation as
+// defined by capture. Body capture is off by default.
+func WithBodyCapture(capture BodyCapture) Option {
+	return func(c *SqsCarrierAttributes) {
+		c.bodyCapture = &capture
+	}
+}
+
+// attributeLimit returns the maximum number of message attributes.
+func (c *SqsCarrierAttributes) attributeLimit() int {
+	if c.maxAttributes > 0 {
+		return c.maxAttributes
+	}
+	return sqsMessageAttributeLimit
+}
@@ -0,0 +1,187 @@
+package otelsqs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testContext returns a context holding a sampled remote span context.
+func testContext(t *testing.T) (context.Context, trace.TraceID) {
+	t.Helper()
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	return ctx, traceID
+}
+
+func TestOptionKeyPrefix(t *testing.T) {
+	ctx, traceID := testContext(t)
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithKeyPrefix("otel."))
+
+	attributes := map[string]types.MessageAttributeValue{}
+
+	if errInject := carrier.Inject(ctx, attributes); errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+
+	if _, found := attributes["otel.traceparent"]; !found {
+		t.Errorf("missing prefixed attribute: %v", attributes)
+	}
+	if _, found := attributes["traceparent"]; found {
+		t.Errorf("unexpected unprefixed attribute")
+	}
+
+	sc := trace.SpanContextFromContext(carrier.Extract(context.TODO(), attributes))
+	if sc.TraceID() != traceID {
+		t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+	}
+}
+
+func TestOptionDataTypeBinary(t *testing.T) {
+	ctx, traceID := testContext(t)
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithDataType("Binary"))
+
+	attributes := map[string]types.MessageAttributeValue{}
+
+	if errInject := carrier.Inject(ctx, attributes); errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+
+	attr := attributes["traceparent"]
+	if aws.ToString(attr.DataType) != "Binary" || attr.StringValue != nil || len(attr.BinaryValue) == 0 {
+		t.Errorf("expected binary attribute, got: %+v", attr)
+	}
+
+	sc := trace.SpanContextFromContext(carrier.Extract(context.TODO(), attributes))
+	if sc.TraceID() != traceID {
+		t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+	}
+}
+
+func TestOptionMaxAttributes(t *testing.T) {
+	ctx, _ := testContext(t)
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMaxAttributes(2))
+
+	attributes := map[string]types.MessageAttributeValue{
+		"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+		"b": {DataType: aws.String("String"), StringValue: aws.String("2")},
+	}
+
+	if errInject := carrier.Inject(ctx, attributes); !errors.Is(errInject, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", errInject)
+	}
+
+	// with validation, attributes added by the propagator are counted as well
+
+	validating := NewCarrier(WithPropagator(propagation.TraceContext{}), WithMaxAttributes(2), WithValidation(true))
+
+	delete(attributes, "b")
+
+	if errInject := validating.Inject(ctx, attributes); errInject != nil {
+		t.Errorf("inject: %v", errInject)
+	}
+
+	attributes = map[string]types.MessageAttributeValue{
+		"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+	}
+
+	// b3 multiple headers need three attributes
+
+	multiple := NewCarrier(WithPropagator(b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))),
+		WithMaxAttributes(2), WithValidation(true))
+
+	if errInject := multiple.Inject(ctx, attributes); !errors.Is(errInject, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", errInject)
+	}
+	if len(attributes) != 1 {
+		t.Errorf("expected attributes unchanged, got: %v", attributes)
+	}
+}
+
+func TestOptionValidation(t *testing.T) {
+	ctx, _ := testContext(t)
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithKeyPrefix("AWS."), WithValidation(true))
+
+	attributes := map[string]types.MessageAttributeValue{}
+
+	if errInject := carrier.Inject(ctx, attributes); !errors.Is(errInject, ErrInvalidAttribute) {
+		t.Errorf("expected ErrInvalidAttribute, got: %v", errInject)
+	}
+	if len(attributes) != 0 {
+		t.Errorf("expected attributes unchanged, got: %v", attributes)
+	}
+
+	// without validation, the attribute is written anyway
+
+	lenient := NewCarrier(WithPropagator(propagation.TraceContext{}), WithKeyPrefix("AWS."))
+
+	if errInject := lenient.Inject(ctx, attributes); errInject != nil {
+		t.Errorf("inject: %v", errInject)
+	}
+	if len(attributes) != 1 {
+		t.Errorf("expected one attribute, got: %v", attributes)
+	}
+}
+
+func TestOptionBodyFallback(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	body := `{"Type":"Notification","Message":"hello","MessageAttributes":{` +
+		`"traceparent":{"Type":"Binary","Value":"` + base64.StdEncoding.EncodeToString([]byte(traceparent)) + `"}}}`
+
+	msg := types.Message{Body: aws.String(body)}
+
+	noFallback := NewCarrier(WithPropagator(propagation.TraceContext{}))
+	if sc := trace.SpanContextFromContext(noFallback.ExtractMessage(context.TODO(), msg)); sc.IsValid() {
+		t.Errorf("unexpected extraction without body fallback")
+	}
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithBodyFallback(true))
+
+	sc := trace.SpanContextFromContext(carrier.ExtractMessage(context.TODO(), msg))
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected traceID: %s", sc.TraceID())
+	}
+
+	// message attributes take precedence over body
+
+	msg.MessageAttributes = map[string]types.MessageAttributeValue{
+		"traceparent": {DataType: aws.String("String"),
+			StringValue: aws.String("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")},
+	}
+
+	sc = trace.SpanContextFromContext(carrier.ExtractMessage(context.TODO(), msg))
+	if sc.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("unexpected traceID: %s", sc.TraceID())
+	}
+}
+
+func TestOptionChaining(t *testing.T) {
+	// zero-option constructor keeps working with method chaining
+
+	carrier := NewCarrier().WithPropagator(propagation.TraceContext{})
+
+	if carrier.attributeLimit() != sqsMessageAttributeLimit {
+		t.Errorf("unexpected default limit: %d", carrier.attributeLimit())
+	}
+
+	if _, isTraceContext := carrier.getPropagator(context.TODO()).(propagation.TraceContext); !isTraceContext {
+		t.Errorf("unexpected propagator")
+	}
+}
@@ -52,61 +52,142 @@ package otelsqs
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/internal/propagator"
+	"github.com/udhos/opentelemetry-trace-sqs/otelcarrier"
 	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const sqsMessageAttributeLimit = 10
 
-var defaultSqsPropagator = b3.New() // b3 single header
+// defaultSqsPropagator holds the default propagator.
+var defaultSqsPropagator = propagator.NewDefault(b3.New()) // b3 single header
 
 // SetTextMapPropagator optionally replaces the default propagator (B3 with single header).
 // Please notice that SQS only supports up to 10 attributes, then be careful when picking
 // another propagator that might consume multiple attributes.
-func SetTextMapPropagator(propagator propagation.TextMapPropagator) {
-	defaultSqsPropagator = propagator
+// SetTextMapPropagator is safe for concurrent use with carriers.
+// Use ContextWithPropagator or WithPropagator to override the propagator
+// without touching the global default.
+func SetTextMapPropagator(p propagation.TextMapPropagator) {
+	defaultSqsPropagator.Set(p)
+}
+
+// ContextWithPropagator returns a copy of ctx carrying a propagator that overrides
+// the default propagator, for carriers invoked with that context.
+// A propagator set on the carrier with WithPropagator still takes precedence.
+// Tests and multi-tenant binaries can thus use different propagators concurrently.
+// The propagator is also seen by otelsns carriers invoked with that context.
+func ContextWithPropagator(ctx context.Context, p propagation.TextMapPropagator) context.Context {
+	return propagator.ContextWith(ctx, p)
+}
+
+// PropagatorFromContext returns the propagator set with ContextWithPropagator, or nil.
+func PropagatorFromContext(ctx context.Context) propagation.TextMapPropagator {
+	return propagator.FromContext(ctx)
+}
+
+// SetTextMapPropagatorFromEnv optionally replaces the default propagator with one built
+// from the standard OTEL_PROPAGATORS env var, for instance "tracecontext,baggage".
+// Supported names are tracecontext, baggage, b3, b3multi, jaeger, xray, ottrace and none.
+// If OTEL_PROPAGATORS is unset or empty, the default propagator is left unchanged.
+// An error is returned for unknown propagator names.
+func SetTextMapPropagatorFromEnv() error {
+	p, errEnv := propagator.FromEnv()
+	if p != nil {
+		SetTextMapPropagator(p)
+	}
+	return errEnv
+}
+
+// SetTextMapPropagatorFromGlobal optionally replaces the default propagator with
+// the OpenTelemetry global propagator, as returned by otel.GetTextMapPropagator().
+// Call it after the global propagator has been configured.
+func SetTextMapPropagatorFromGlobal() {
+	SetTextMapPropagator(otel.GetTextMapPropagator())
 }
 
 // SqsCarrierAttributes is a message attribute carrier for SQS.
-// https://pkg.go.dev/go.opentelemetry.io/otel/propagation#TextMapCarrier
+// Once configured, the carrier is not mutated by Extract, Inject, ExtractMessage
+// and InjectInput, so it can be shared by concurrent workers.
+// To read or write message attributes as a TextMapCarrier, use MessageAttributesCarrier.
 type SqsCarrierAttributes struct {
-	messageAttributes map[string]types.MessageAttributeValue
-	propagator        propagation.TextMapPropagator
+	propagator       propagation.TextMapPropagator
+	location         Location
+	dataType         string
+	keyPrefix        string
+	maxAttributes    int
+	bodyFallback     bool
+	validate         bool
+	tracerProvider   trace.TracerProvider
+	queueTimeSpan    bool
+	metrics          *carrierMetrics
+	extractPolicy    ExtractPolicy
+	captureNames     []string
+	captureHash      map[string]bool
+	captureHashKey   []byte
+	captureMaxLength int
+	bodyCapture      *BodyCapture
 }
 
 // NewCarrier creates a carrier for SQS.
-func NewCarrier() *SqsCarrierAttributes {
+// Options are applied in order, see Option.
+//
+// Example:
+//
+//	carrier := otelsqs.NewCarrier(otelsqs.WithPreset(otelsqs.PresetW3C), otelsqs.WithValidation(true))
+func NewCarrier(options ...Option) *SqsCarrierAttributes {
 	c := &SqsCarrierAttributes{}
-	return c.WithPropagator(defaultSqsPropagator)
+	for _, o := range options {
+		o(c)
+	}
+	return c
 }
 
-// WithPropagator sets propagator for carrier. If unspecified, carrier uses default propagator defined with SetTextMapPropagator.
+// WithPropagator sets propagator for carrier.
+// If unspecified, carrier uses the propagator from context set with ContextWithPropagator,
+// or else the default propagator defined with SetTextMapPropagator.
 func (c *SqsCarrierAttributes) WithPropagator(propagator propagation.TextMapPropagator) *SqsCarrierAttributes {
 	c.propagator = propagator
 	return c
 }
 
-// attach attaches carrier to SQS message.
-func (c *SqsCarrierAttributes) attach(messageAttributes map[string]types.MessageAttributeValue) {
-	if messageAttributes == nil {
-		panic("messageAttributes map is nil")
-	}
-	c.messageAttributes = messageAttributes
+// getPropagator resolves the propagator for carrier: the one set with WithPropagator,
+// then the one from ctx, then the default.
+func (c *SqsCarrierAttributes) getPropagator(ctx context.Context) propagation.TextMapPropagator {
+	return defaultSqsPropagator.Resolve(ctx, c.propagator)
 }
 
 // Extract gets a tracing context from SQS message attributes.
 // `messageAttributes` should point to incoming SQS message MessageAttributes (possibly) carring trace information.
 // If `messageAttributes` is nil, ctx is returned unchanged.
+// With WithMeterProvider, a message carrying no trace context is counted in
+// MetricTraceContextMissing, without a destination.
 // Use Extract right after receiving an SQS message.
 func (c *SqsCarrierAttributes) Extract(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) context.Context {
-	if messageAttributes == nil {
-		return ctx
+	carrier := c.carrier(messageAttributes, nil, nil)
+	ctxNew := ctx
+	if messageAttributes != nil {
+		ctxNew = c.getPropagator(ctx).Extract(ctx, carrier)
 	}
-	c.attach(messageAttributes)
-	return c.propagator.Extract(ctx, c)
+	if !extracted(ctx, ctxNew) {
+		c.recordMissing(ctx, "", carrier, len(messageAttributes))
+	}
+	return ctxNew
+}
+
+// Extract gets a tracing context from SQS message attributes, using the propagator
+// from ctx set with ContextWithPropagator, or else the default propagator.
+// It is safe for concurrent use. See SqsCarrierAttributes.Extract.
+func Extract(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) context.Context {
+	var c SqsCarrierAttributes
+	return c.Extract(ctx, messageAttributes)
 }
 
 var (
@@ -115,6 +196,9 @@ var (
 
 	// ErrMessageAttributesIsNil rejects nil message attributes.
 	ErrMessageAttributesIsNil = errors.New("message attributes is nil")
+
+	// ErrInvalidAttribute signals an attribute that SQS would refuse.
+	ErrInvalidAttribute = errors.New("invalid message attribute")
 )
 
 // Inject inserts tracing from context into the SQS message attributes.
@@ -122,50 +206,108 @@ var (
 // `messageAttributes` should point to outgoing SQS message MessageAttributes which will carry the trace information.
 // If `messageAttributes` is nil, error ErrMessageAttributesIsNil will be returned.
 // If `messageAttributes` holds 10 or more items, Inject will do nothing and return ErrMaxAttrLimit,
-// since SQS refuses messages with more than 10 attributes.
+// since SQS refuses messages with more than 10 attributes. The limit can be changed with WithMaxAttributes.
+// With WithValidation, Inject also returns ErrMaxAttrLimit or ErrInvalidAttribute when attributes
+// written by the propagator would be refused by SQS, leaving `messageAttributes` unchanged.
 // Use Inject right before sending out the SQS message.
+// With WithMeterProvider, failures are counted without destination, since
+// Inject does not know the queue; InjectInput counts them by QueueUrl.
 func (c *SqsCarrierAttributes) Inject(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) error {
+	err := c.injectAttributes(ctx, messageAttributes)
+	if err != nil {
+		c.recordFailure(ctx, "inject", "", errorReason(err))
+	}
+	return err
+}
+
+func (c *SqsCarrierAttributes) injectAttributes(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) error {
 	if messageAttributes == nil {
 		return ErrMessageAttributesIsNil
 	}
-	if len(messageAttributes) >= sqsMessageAttributeLimit {
+	if len(messageAttributes) >= c.attributeLimit() {
 		return ErrMaxAttrLimit
 	}
-	c.attach(messageAttributes)
-	c.propagator.Inject(ctx, c)
-	return nil
+	return c.inject(ctx, messageAttributes, nil)
 }
 
-// Get returns the value for the key.
-func (c *SqsCarrierAttributes) Get(key string) string {
-	if c.messageAttributes == nil {
-		return ""
-	}
-	attr, found := c.messageAttributes[key]
-	if !found {
-		return ""
-	}
-	return aws.ToString(attr.StringValue)
+// Inject inserts tracing from context into the SQS message attributes, using the
+// propagator from ctx set with ContextWithPropagator, or else the default propagator.
+// It is safe for concurrent use. See SqsCarrierAttributes.Inject.
+func Inject(ctx context.Context, messageAttributes map[string]types.MessageAttributeValue) error {
+	var c SqsCarrierAttributes
+	return c.Inject(ctx, messageAttributes)
 }
 
-const stringType = "String"
+// MessageAttributesCarrier adapts SQS message attributes to propagation.TextMapCarrier.
+// It holds no state besides the map itself, hence concurrent use is as safe as concurrent
+// use of the underlying map. Set stores attributes with data type String.
+//
+// Example:
+//
+//	ctx := propagator.Extract(context.Background(), otelsqs.MessageAttributesCarrier(msg.MessageAttributes))
+type MessageAttributesCarrier = otelcarrier.Carrier[types.MessageAttributeValue, AttributeAccessor]
+
+// AttributeAccessor converts SQS message attribute values for otelcarrier.Carrier.
+type AttributeAccessor struct{}
 
-// Set stores a key-value pair.
-func (c *SqsCarrierAttributes) Set(key, value string) {
-	if c.messageAttributes == nil {
-		return
+// Text returns the attribute value, either String or Binary, as text.
+// Binary attributes, as sent by some tracers through SNS, are returned as text.
+func (AttributeAccessor) Text(value types.MessageAttributeValue) string {
+	if value.StringValue == nil {
+		return string(value.BinaryValue)
 	}
-	c.messageAttributes[key] = types.MessageAttributeValue{
+	return aws.ToString(value.StringValue)
+}
+
+// Value creates an attribute value with data type String.
+func (AttributeAccessor) Value(text string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
 		DataType:    aws.String(stringType),
-		StringValue: aws.String(value),
+		StringValue: aws.String(text),
 	}
 }
 
-// Keys lists the keys in the carrier.
-func (c *SqsCarrierAttributes) Keys() []string {
-	keys := make([]string, 0, len(c.messageAttributes))
-	for k := range c.messageAttributes {
-		keys = append(keys, k)
+// TypedValue creates an attribute value with dataType, or String if dataType is empty.
+// Binary types hold the text as BinaryValue.
+func (AttributeAccessor) TypedValue(text, dataType string) types.MessageAttributeValue {
+	if dataType == "" {
+		dataType = stringType
+	}
+	if strings.HasPrefix(dataType, binaryType) {
+		return types.MessageAttributeValue{
+			DataType:    aws.String(dataType),
+			BinaryValue: []byte(text),
+		}
 	}
-	return keys
+	return types.MessageAttributeValue{
+		DataType:    aws.String(dataType),
+		StringValue: aws.String(text),
+	}
+}
+
+// prefixCarrier adapts message attributes named with prefix and written with data type.
+type prefixCarrier = otelcarrier.PrefixCarrier[types.MessageAttributeValue, AttributeAccessor]
+
+// Get always returns an empty string, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(msg.MessageAttributes).Get instead.
+func (c *SqsCarrierAttributes) Get(key string) string {
+	return ""
+}
+
+const (
+	stringType = "String"
+	binaryType = "Binary"
+)
+
+// Set does nothing, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(msg.MessageAttributes).Set instead.
+func (c *SqsCarrierAttributes) Set(key, value string) {}
+
+// Keys always returns nil, since the carrier holds no message attributes.
+//
+// Deprecated: use MessageAttributesCarrier(msg.MessageAttributes).Keys instead.
+func (c *SqsCarrierAttributes) Keys() []string {
+	return nil
 }
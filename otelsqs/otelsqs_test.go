@@ -2,11 +2,18 @@ package otelsqs
 
 import (
 	"context"
+	"crypto/rand"
 	"log"
+	"slices"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/udhos/otelconfig/oteltrace"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -125,8 +132,7 @@ func TestSqsCarrierAttributes(t *testing.T) {
 	sqsMessage := types.Message{
 		MessageAttributes: make(map[string]types.MessageAttributeValue),
 	}
-	carrier := NewCarrier()
-	carrier.attach(sqsMessage.MessageAttributes)
+	carrier := MessageAttributesCarrier(sqsMessage.MessageAttributes)
 
 	// no keys
 
@@ -230,3 +236,227 @@ func TestSqsCarrierAttributes(t *testing.T) {
 		t.Errorf("wrong value for key3")
 	}
 }
+
+func TestSetTextMapPropagatorFromEnv(t *testing.T) {
+	saved := defaultSqsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	testCases := []struct {
+		name         string
+		env          string
+		expectFields []string
+		expectError  bool
+	}{
+		{"unset keeps default", "", nil, false},
+		{"tracecontext", "tracecontext", []string{"traceparent", "tracestate"}, false},
+		{"list with spaces", " tracecontext , xray ", []string{"X-Amzn-Trace-Id", "traceparent", "tracestate"}, false},
+		{"unknown", "tracecontext,unknown", nil, true},
+	}
+
+	for _, data := range testCases {
+		t.Run(data.name, func(t *testing.T) {
+			SetTextMapPropagator(saved)
+			t.Setenv("OTEL_PROPAGATORS", data.env)
+
+			errEnv := SetTextMapPropagatorFromEnv()
+			if (errEnv != nil) != data.expectError {
+				t.Errorf("expected error:%t got error:%v", data.expectError, errEnv)
+			}
+
+			expectFields := data.expectFields
+			if expectFields == nil {
+				expectFields = slices.Sorted(slices.Values(saved.Fields())) // default unchanged
+			}
+
+			fields := NewCarrier().getPropagator(context.TODO()).Fields()
+			slices.Sort(fields) // composite propagator fields are unordered
+			if !slices.Equal(fields, expectFields) {
+				t.Errorf("expected fields:%v got fields:%v", expectFields, fields)
+			}
+		})
+	}
+}
+
+func TestSetTextMapPropagatorFromGlobal(t *testing.T) {
+	saved := defaultSqsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	savedGlobal := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(savedGlobal) })
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	SetTextMapPropagatorFromGlobal()
+
+	fields := NewCarrier().getPropagator(context.TODO()).Fields()
+	slices.Sort(fields)
+	if expect := []string{"traceparent", "tracestate"}; !slices.Equal(fields, expect) {
+		t.Errorf("expected fields:%v got fields:%v", expect, fields)
+	}
+}
+
+func TestPropagatorConcurrency(t *testing.T) {
+	saved := defaultSqsPropagator.Get()
+	t.Cleanup(func() { SetTextMapPropagator(saved) })
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	scoped := []struct {
+		propagator propagation.TextMapPropagator
+		key        string
+	}{
+		{propagation.TraceContext{}, "traceparent"},
+		{b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)), "x-b3-traceid"},
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// keep swapping the global default
+	wg.Go(func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				SetTextMapPropagator(propagation.TraceContext{})
+			} else {
+				SetTextMapPropagator(b3.New())
+			}
+		}
+	})
+
+	var workers sync.WaitGroup
+
+	for i := range 8 {
+		s := scoped[i%len(scoped)]
+		workers.Go(func() {
+			ctxScoped := ContextWithPropagator(ctx, s.propagator)
+			for range 100 {
+				attributes := make(map[string]types.MessageAttributeValue)
+				carrier := NewCarrier()
+				if errInject := carrier.Inject(ctxScoped, attributes); errInject != nil {
+					t.Errorf("inject: %v", errInject)
+					return
+				}
+				if _, found := attributes[s.key]; !found {
+					t.Errorf("scoped propagator: missing key %s: %v", s.key, attributes)
+					return
+				}
+				sc := trace.SpanContextFromContext(carrier.Extract(ctxScoped, attributes))
+				if sc.TraceID() != traceID {
+					t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+					return
+				}
+				// also exercise the global default, whatever it is right now
+				_ = carrier.Inject(ctx, make(map[string]types.MessageAttributeValue))
+			}
+		})
+	}
+
+	workers.Wait()
+	close(done)
+	wg.Wait()
+}
+
+func TestSharedCarrierConcurrency(t *testing.T) {
+
+	carrier := NewCarrier().WithPropagator(propagation.TraceContext{})
+
+	var workers sync.WaitGroup
+
+	for range 8 {
+		workers.Go(func() {
+			for range 100 {
+				var traceID trace.TraceID
+				var spanID trace.SpanID
+				rand.Read(traceID[:])
+				rand.Read(spanID[:])
+
+				ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}))
+
+				// shared carrier
+				attributes := make(map[string]types.MessageAttributeValue)
+				if errInject := carrier.Inject(ctx, attributes); errInject != nil {
+					t.Errorf("inject: %v", errInject)
+					return
+				}
+				if sc := trace.SpanContextFromContext(carrier.Extract(context.TODO(), attributes)); sc.TraceID() != traceID {
+					t.Errorf("carrier: traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+					return
+				}
+
+				// package-level functions
+				attributes = make(map[string]types.MessageAttributeValue)
+				ctxScoped := ContextWithPropagator(ctx, propagation.TraceContext{})
+				if errInject := Inject(ctxScoped, attributes); errInject != nil {
+					t.Errorf("inject: %v", errInject)
+					return
+				}
+				if sc := trace.SpanContextFromContext(Extract(ctxScoped, attributes)); sc.TraceID() != traceID {
+					t.Errorf("package: traceIDSent:%s mismatches traceIDRecv:%s", traceID, sc.TraceID())
+					return
+				}
+			}
+		})
+	}
+
+	workers.Wait()
+}
+
+// getPropagator only reads one key, hence allocates nothing.
+type getPropagator struct{}
+
+func (getPropagator) Inject(_ context.Context, carrier propagation.TextMapCarrier) {
+	carrier.Get("key")
+}
+
+func (getPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	carrier.Get("key")
+	return ctx
+}
+
+func (getPropagator) Fields() []string {
+	return []string{"key"}
+}
+
+func TestExtractInjectAllocs(t *testing.T) {
+
+	ctx := ContextWithPropagator(context.TODO(), getPropagator{})
+
+	attributes := map[string]types.MessageAttributeValue{
+		"key": {DataType: aws.String("String"), StringValue: aws.String("value")},
+	}
+
+	carrier := NewCarrier()
+
+	testCases := []struct {
+		name string
+		f    func()
+	}{
+		{"Extract", func() { Extract(ctx, attributes) }},
+		{"Inject", func() { _ = Inject(ctx, attributes) }},
+		{"carrier.Extract", func() { carrier.Extract(ctx, attributes) }},
+		{"carrier.Inject", func() { _ = carrier.Inject(ctx, attributes) }},
+	}
+
+	for _, data := range testCases {
+		t.Run(data.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(100, data.f); allocs != 0 {
+				t.Errorf("expected no allocations, got %v", allocs)
+			}
+		})
+	}
+}
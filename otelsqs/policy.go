@@ -0,0 +1,51 @@
+package otelsqs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExtractPolicy defines how StartConsumerSpan uses the trace context extracted
+// from a received message. Pick a policy per queue to make trust boundaries explicit.
+type ExtractPolicy int
+
+const (
+	// ExtractParent continues the producer trace, parenting the consumer span
+	// to the extracted context. This is the default.
+	ExtractParent ExtractPolicy = iota
+
+	// ExtractLink starts a new trace, linking the consumer span to the extracted
+	// context. Use it for queues fed by external parties, so that their trace IDs
+	// neither root our traces nor drive our sampling.
+	ExtractLink
+
+	// ExtractIgnore starts a new trace, ignoring any trace context in the message.
+	ExtractIgnore
+)
+
+// String returns the policy name, as accepted by ParseExtractPolicy.
+func (p ExtractPolicy) String() string {
+	switch p {
+	case ExtractParent:
+		return "parent"
+	case ExtractLink:
+		return "link"
+	case ExtractIgnore:
+		return "ignore"
+	}
+	return fmt.Sprintf("ExtractPolicy(%d)", int(p))
+}
+
+// ParseExtractPolicy converts a policy name, either "parent", "link" or "ignore",
+// into ExtractPolicy. Empty name selects ExtractParent.
+func ParseExtractPolicy(name string) (ExtractPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "parent":
+		return ExtractParent, nil
+	case "link":
+		return ExtractLink, nil
+	case "ignore":
+		return ExtractIgnore, nil
+	}
+	return ExtractParent, fmt.Errorf("unknown extract policy: %q", name)
+}
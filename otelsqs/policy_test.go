@@ -0,0 +1,110 @@
+package otelsqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseExtractPolicy(t *testing.T) {
+	table := []struct {
+		name     string
+		expected ExtractPolicy
+	}{
+		{"", ExtractParent},
+		{"parent", ExtractParent},
+		{"Link", ExtractLink},
+		{" ignore ", ExtractIgnore},
+	}
+	for _, data := range table {
+		policy, err := ParseExtractPolicy(data.name)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", data.name, err)
+		}
+		if policy != data.expected {
+			t.Errorf("%q: expected %v, got %v", data.name, data.expected, policy)
+		}
+		if data.name != "" {
+			if roundTrip, _ := ParseExtractPolicy(policy.String()); roundTrip != policy {
+				t.Errorf("%q: round trip mismatch: %v", data.name, roundTrip)
+			}
+		}
+	}
+
+	if _, err := ParseExtractPolicy("follow"); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
+
+func TestExtractPolicy(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	msg := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": {DataType: aws.String("String"),
+				StringValue: aws.String("00-" + traceID + "-00f067aa0ba902b7-01")},
+		},
+	}
+
+	// a trace already active in the consumer, such as a Lambda invocation,
+	// must not parent spans for policies starting new traces
+	ambient, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	ambientTrace, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	ctxAmbient := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    ambientTrace,
+		SpanID:     ambient,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	table := []struct {
+		policy     ExtractPolicy
+		sameTrace  bool
+		linked     bool
+		hasMissing bool
+	}{
+		{ExtractParent, true, false, false},
+		{ExtractLink, false, true, false},
+		{ExtractIgnore, false, false, false},
+	}
+
+	for _, data := range table {
+		t.Run(data.policy.String(), func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			carrier := NewCarrier(WithPropagator(propagation.TraceContext{}),
+				WithTracerProvider(provider), WithExtractPolicy(data.policy))
+
+			_, span := carrier.StartConsumerSpan(ctxAmbient, testQueueURL, msg)
+			span.End()
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			s := spans[0]
+
+			if sameTrace := s.SpanContext().TraceID().String() == traceID; sameTrace != data.sameTrace {
+				t.Errorf("sameTrace=%t expected=%t", sameTrace, data.sameTrace)
+			}
+			if !data.sameTrace && s.Parent().IsValid() {
+				t.Errorf("expected root span, got parent: %v", s.Parent())
+			}
+
+			linked := len(s.Links()) == 1 && s.Links()[0].SpanContext.TraceID().String() == traceID
+			if linked != data.linked {
+				t.Errorf("linked=%t expected=%t", linked, data.linked)
+			}
+
+			if _, hasMissing := findAttribute(s.Attributes(), MissingAttribute); hasMissing != data.hasMissing {
+				t.Errorf("hasMissing=%t expected=%t", hasMissing, data.hasMissing)
+			}
+		})
+	}
+}
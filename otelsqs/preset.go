@@ -0,0 +1,97 @@
+package otelsqs
+
+import (
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Location selects where trace context is kept in an SQS message.
+type Location int
+
+const (
+	// LocationMessageAttributes keeps trace context in message attributes.
+	LocationMessageAttributes Location = iota
+
+	// LocationSystemAttribute keeps the X-Ray trace header in the AWSTraceHeader
+	// message system attribute. Fields other than the X-Ray header are dropped,
+	// since SQS accepts no other system attribute on send.
+	LocationSystemAttribute
+
+	// LocationBoth keeps the X-Ray trace header in the AWSTraceHeader message
+	// system attribute and every other field in message attributes.
+	LocationBoth
+)
+
+// Preset bundles propagator, attribute location and data type matching the
+// SQS conventions of another OpenTelemetry SDK.
+type Preset struct {
+	// Name identifies the preset, see PresetByName.
+	Name string
+
+	// Propagator encodes and decodes trace context.
+	Propagator propagation.TextMapPropagator
+
+	// Location selects where trace context is kept in the message.
+	Location Location
+
+	// DataType is the data type of message attributes written by the carrier.
+	DataType string
+}
+
+var (
+	// PresetB3 uses B3 single header in message attributes.
+	// This is the carrier default.
+	PresetB3 = Preset{
+		Name:       "b3",
+		Propagator: b3.New(),
+		Location:   LocationMessageAttributes,
+		DataType:   stringType,
+	}
+
+	// PresetW3C uses W3C traceparent, tracestate and baggage in message attributes,
+	// as done by the Python (botocore) and Node (aws-sdk) instrumentations.
+	PresetW3C = Preset{
+		Name:       "w3c",
+		Propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+		Location:   LocationMessageAttributes,
+		DataType:   stringType,
+	}
+
+	// PresetXRay uses the X-Ray trace header in the AWSTraceHeader system attribute,
+	// as done by the AWS X-Ray SDKs and by Lambda with active tracing.
+	PresetXRay = Preset{
+		Name:       "xray",
+		Propagator: xray.Propagator{},
+		Location:   LocationSystemAttribute,
+		DataType:   stringType,
+	}
+
+	// PresetJavaAgent uses the X-Ray trace header in the AWSTraceHeader system attribute
+	// plus W3C traceparent in message attributes, as done by the Java agent with
+	// messaging propagation enabled. When both are present, traceparent wins.
+	PresetJavaAgent = Preset{
+		Name: "java-agent",
+		Propagator: propagation.NewCompositeTextMapPropagator(xray.Propagator{},
+			propagation.TraceContext{}, propagation.Baggage{}),
+		Location: LocationBoth,
+		DataType: stringType,
+	}
+)
+
+// PresetByName finds a preset by its name: b3, w3c, xray or java-agent.
+func PresetByName(name string) (Preset, bool) {
+	for _, p := range []Preset{PresetB3, PresetW3C, PresetXRay, PresetJavaAgent} {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// WithPreset sets propagator, location and data type for carrier from preset.
+func (c *SqsCarrierAttributes) WithPreset(preset Preset) *SqsCarrierAttributes {
+	c.location = preset.Location
+	c.dataType = preset.DataType
+	return c.WithPropagator(preset.Propagator)
+}
@@ -0,0 +1,197 @@
+package otelsqs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fixture origins. Synthetic fixtures are built from the wire format and the
+// documented conventions of each SDK, hence only check that the carrier agrees
+// with our reading of them; captured fixtures are recorded from real SQS.
+const (
+	originSynthetic = "synthetic"
+	originCaptured  = "captured"
+)
+
+// fixture is the raw ReceiveMessage response body, in the SQS JSON protocol,
+// for a message sent by another SDK, along with the trace context it must
+// extract to. See testdata/fixtures/README.md.
+type fixture struct {
+	SDK      string          `json:"sdk"`
+	Preset   string          `json:"preset"`
+	Origin   string          `json:"origin"`
+	TraceID  string          `json:"traceId"`
+	SpanID   string          `json:"spanId"`
+	Response json.RawMessage `json:"response"`
+}
+
+// receiveFixture decodes response with the SDK client, as if received from SQS,
+// so fixtures go through the same deserialization and checksum validation.
+func receiveFixture(t *testing.T, response []byte) types.Message {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	client := sqs.New(sqs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	out, err := client.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
+		QueueUrl:                    aws.String(testQueueURL),
+		MessageAttributeNames:       []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{"All"},
+	})
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if len(out.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(out.Messages))
+	}
+	return out.Messages[0]
+}
+
+func TestPresetFixtures(t *testing.T) {
+
+	files, errGlob := filepath.Glob("testdata/fixtures/*.json")
+	if errGlob != nil {
+		t.Fatalf("glob: %v", errGlob)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no fixtures found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, errRead := os.ReadFile(file)
+			if errRead != nil {
+				t.Fatalf("read: %v", errRead)
+			}
+
+			var f fixture
+			if errJSON := json.Unmarshal(data, &f); errJSON != nil {
+				t.Fatalf("json: %v", errJSON)
+			}
+
+			switch f.Origin {
+			case originSynthetic:
+				t.Logf("%s: synthetic fixture, not captured from SQS", f.SDK)
+			case originCaptured:
+			default:
+				t.Fatalf("%s: origin must be %q or %q, got %q", f.SDK, originSynthetic, originCaptured, f.Origin)
+			}
+
+			preset, found := PresetByName(f.Preset)
+			if !found {
+				t.Fatalf("%s: unknown preset: %s", f.SDK, f.Preset)
+			}
+
+			carrier := NewCarrier().WithPreset(preset)
+
+			msg := receiveFixture(t, f.Response)
+
+			sc := trace.SpanContextFromContext(carrier.ExtractMessage(context.TODO(), msg))
+
+			if sc.TraceID().String() != f.TraceID {
+				t.Errorf("%s: expected traceID:%s got traceID:%s", f.SDK, f.TraceID, sc.TraceID())
+			}
+			if sc.SpanID().String() != f.SpanID {
+				t.Errorf("%s: expected spanID:%s got spanID:%s", f.SDK, f.SpanID, sc.SpanID())
+			}
+		})
+	}
+}
+
+func TestPresetInjectExtract(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	ctx := trace.ContextWithSpanContext(context.TODO(), sc)
+
+	testCases := []struct {
+		preset                  Preset
+		expectMessageAttributes []string
+		expectSystemAttribute   bool
+	}{
+		{PresetB3, []string{"b3"}, false},
+		{PresetW3C, []string{"traceparent"}, false},
+		{PresetXRay, nil, true},
+		{PresetJavaAgent, []string{"traceparent"}, true},
+	}
+
+	for _, data := range testCases {
+		t.Run(data.preset.Name, func(t *testing.T) {
+
+			//
+			// Send
+			//
+
+			input := &sqs.SendMessageInput{
+				MessageBody: aws.String("hello"),
+			}
+
+			carrier := NewCarrier().WithPreset(data.preset)
+			if errInject := carrier.InjectInput(ctx, input); errInject != nil {
+				t.Errorf("inject: %v", errInject)
+			}
+
+			if len(input.MessageAttributes) != len(data.expectMessageAttributes) {
+				t.Errorf("expected message attributes %v, got %v", data.expectMessageAttributes, input.MessageAttributes)
+			}
+			for _, k := range data.expectMessageAttributes {
+				if _, found := input.MessageAttributes[k]; !found {
+					t.Errorf("missing message attribute: %s", k)
+				}
+			}
+
+			_, foundSystem := input.MessageSystemAttributes[AWSTraceHeader]
+			if foundSystem != data.expectSystemAttribute {
+				t.Errorf("expected system attribute %s: %t, got %t", AWSTraceHeader, data.expectSystemAttribute, foundSystem)
+			}
+
+			//
+			// Receive
+			//
+
+			msg := types.Message{
+				Body:              input.MessageBody,
+				MessageAttributes: input.MessageAttributes,
+				Attributes:        map[string]string{},
+			}
+			for k, v := range input.MessageSystemAttributes {
+				msg.Attributes[k] = aws.ToString(v.StringValue)
+			}
+
+			scRecv := trace.SpanContextFromContext(carrier.ExtractMessage(context.TODO(), msg))
+
+			if scRecv.TraceID() != traceID {
+				t.Errorf("traceIDSent:%s mismatches traceIDRecv:%s", traceID, scRecv.TraceID())
+			}
+			if scRecv.SpanID() != spanID {
+				t.Errorf("spanIDSent:%s mismatches spanIDRecv:%s", spanID, scRecv.SpanID())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package otelsqs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartProducerSpan starts a producer span for sending input, then injects the
+// span context into input with InjectInput, so that consumers continue the trace
+// from the producer span. If injection fails, the error is recorded on the span
+// and returned, but the span is still started: the caller may send the message anyway.
+// The caller must end the returned span after sending the message.
+//
+// Example:
+//
+//	ctx, span, errInject := carrier.StartProducerSpan(ctx, input)
+//	if errInject != nil {
+//	    log.Printf("inject error: %v", errInject)
+//	}
+//	_, errSend := client.SendMessage(ctx, input)
+//	span.End()
+func (c *SqsCarrierAttributes) StartProducerSpan(ctx context.Context, input *sqs.SendMessageInput,
+	opts ...trace.SpanStartOption) (context.Context, trace.Span, error) {
+
+	queueURL := aws.ToString(input.QueueUrl)
+	queue := queueName(queueURL)
+
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemAWSSQS,
+		semconv.MessagingDestinationName(queue),
+		semconv.MessagingOperationTypeSend,
+	}
+	attrs = append(attrs, c.capturedAttributes(input.MessageAttributes)...)
+
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	}
+
+	ctxSpan, span := c.tracer().Start(ctx, "send "+queue, append(options, opts...)...)
+
+	c.recordBody(span, input.MessageBody)
+
+	errInject := c.InjectInput(ctxSpan, input)
+	if errInject != nil {
+		span.RecordError(errInject)
+		span.SetStatus(codes.Error, errInject.Error())
+	}
+
+	return ctxSpan, span, errInject
+}
@@ -0,0 +1,78 @@
+package otelsqs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartProducerSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithPropagator(propagation.TraceContext{}), WithTracerProvider(provider),
+		WithCapturedAttributes("tenant"))
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(testQueueURL),
+		MessageBody: aws.String("hello"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+		},
+	}
+
+	_, span, errInject := carrier.StartProducerSpan(context.TODO(), input)
+	if errInject != nil {
+		t.Fatalf("inject: %v", errInject)
+	}
+	span.End()
+
+	s := recorder.Ended()[0]
+
+	if s.Name() != "send orders" || s.SpanKind() != trace.SpanKindProducer {
+		t.Errorf("unexpected span: name=%s kind=%v", s.Name(), s.SpanKind())
+	}
+	if _, found := findAttribute(s.Attributes(), MessageAttributePrefix+"tenant"); !found {
+		t.Errorf("missing captured attribute tenant")
+	}
+
+	// consumer continues from the producer span
+
+	sc := trace.SpanContextFromContext(carrier.Extract(context.TODO(), input.MessageAttributes))
+	if sc.SpanID() != s.SpanContext().SpanID() {
+		t.Errorf("extracted spanID:%s mismatches producer spanID:%s", sc.SpanID(), s.SpanContext().SpanID())
+	}
+}
+
+func TestStartProducerSpanInjectError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	carrier := NewCarrier(WithTracerProvider(provider), WithMaxAttributes(1))
+
+	input := &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"a": {DataType: aws.String("String"), StringValue: aws.String("1")},
+		},
+	}
+
+	_, span, errInject := carrier.StartProducerSpan(context.TODO(), input)
+	if !errors.Is(errInject, ErrMaxAttrLimit) {
+		t.Errorf("expected ErrMaxAttrLimit, got: %v", errInject)
+	}
+	span.End()
+
+	if status := recorder.Ended()[0].Status(); status.Code != codes.Error {
+		t.Errorf("expected error status, got: %v", status)
+	}
+}
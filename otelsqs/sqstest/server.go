@@ -0,0 +1,187 @@
+package sqstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+// targetPrefix prefixes the action in header X-Amz-Target.
+const targetPrefix = "AmazonSQS."
+
+// action handles one SQS API call, decoding the JSON request body.
+type action func(ctx context.Context, body []byte) (any, error)
+
+// Handler serves the SQS JSON protocol over HTTP, backed by an in-memory SQS.
+// Point the SDK at it with BaseEndpoint (or env var AWS_ENDPOINT_URL_SQS).
+// Unlike the SQS methods, operations on a queue require it to exist.
+type Handler struct {
+	sqs     *SQS
+	actions map[string]action
+	debug   bool
+}
+
+// NewHandler creates a handler serving s.
+// If debug is true, the handler logs every call.
+func NewHandler(s *SQS, debug bool) *Handler {
+	h := &Handler{sqs: s, debug: debug}
+	h.actions = map[string]action{
+		"CreateQueue":                  operation(h.createQueue),
+		"GetQueueUrl":                  operation(h.getQueueURL),
+		"ListQueues":                   operation(h.listQueues),
+		"DeleteQueue":                  operation(h.deleteQueue),
+		"PurgeQueue":                   operation(h.purgeQueue),
+		"SendMessage":                  operation(s.SendMessage),
+		"SendMessageBatch":             operation(s.SendMessageBatch),
+		"ReceiveMessage":               operation(s.ReceiveMessage),
+		"DeleteMessage":                operation(s.DeleteMessage),
+		"DeleteMessageBatch":           operation(s.DeleteMessageBatch),
+		"ChangeMessageVisibility":      operation(s.ChangeMessageVisibility),
+		"ChangeMessageVisibilityBatch": operation(s.ChangeMessageVisibilityBatch),
+	}
+	return h
+}
+
+// operation adapts an SQS API method to an action.
+func operation[I, O any](fn func(context.Context, *I, ...func(*sqs.Options)) (*O, error)) action {
+	return func(ctx context.Context, body []byte) (any, error) {
+		var input I
+		if err := json.Unmarshal(body, &input); err != nil {
+			return nil, invalidParameter("malformed request: %v", err)
+		}
+		return fn(ctx, &input)
+	}
+}
+
+// ServeHTTP dispatches the action named by header X-Amz-Target.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	name, found := strings.CutPrefix(target, targetPrefix)
+	call, supported := h.actions[name]
+	if r.Method != http.MethodPost || !found || !supported {
+		h.writeError(w, target, &smithy.GenericAPIError{
+			Code:    "UnsupportedOperation",
+			Message: "unsupported operation: " + target,
+			Fault:   smithy.FaultClient,
+		})
+		return
+	}
+
+	body, errRead := io.ReadAll(r.Body)
+	if errRead != nil {
+		h.writeError(w, name, invalidParameter("read request: %v", errRead))
+		return
+	}
+
+	if err := h.checkQueue(name, body); err != nil {
+		h.writeError(w, name, err)
+		return
+	}
+
+	output, err := call(r.Context(), body)
+	if err != nil {
+		h.writeError(w, name, err)
+		return
+	}
+
+	if h.debug {
+		log.Printf("sqstest: %s: %s", name, body)
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if errEncode := json.NewEncoder(w).Encode(output); errEncode != nil {
+		log.Printf("sqstest: %s: encode response: %v", name, errEncode)
+	}
+}
+
+// checkQueue refuses calls on queues that do not exist, like SQS.
+func (h *Handler) checkQueue(name string, body []byte) error {
+	if name == "CreateQueue" || name == "GetQueueUrl" || name == "ListQueues" {
+		return nil
+	}
+	var input struct{ QueueUrl string }
+	if err := json.Unmarshal(body, &input); err != nil {
+		return invalidParameter("malformed request: %v", err)
+	}
+	if !h.sqs.QueueExists(input.QueueUrl) {
+		return queueDoesNotExist(input.QueueUrl)
+	}
+	return nil
+}
+
+func queueDoesNotExist(queue string) error {
+	return &types.QueueDoesNotExist{Message: aws.String("queue does not exist: " + queue)}
+}
+
+// writeError encodes err as expected by the SDK for the SQS JSON protocol.
+func (h *Handler) writeError(w http.ResponseWriter, name string, err error) {
+	code, status, fault := "InternalFailure", http.StatusInternalServerError, "Receiver"
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code, status, fault = apiErr.ErrorCode(), http.StatusBadRequest, "Sender"
+	}
+
+	if h.debug {
+		log.Printf("sqstest: %s: error: %v", name, err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amzn-Query-Error", code+";"+fault)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"__type":  "com.amazonaws.sqs#" + code,
+		"message": err.Error(),
+	})
+}
+
+func (h *Handler) createQueue(_ context.Context, input *sqs.CreateQueueInput,
+	_ ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	name := aws.ToString(input.QueueName)
+	if name == "" || strings.Contains(name, "/") {
+		return nil, invalidParameter("invalid queue name: %q", name)
+	}
+	h.sqs.CreateQueue(name)
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(QueueURL(name))}, nil
+}
+
+func (h *Handler) getQueueURL(_ context.Context, input *sqs.GetQueueUrlInput,
+	_ ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	name := aws.ToString(input.QueueName)
+	if !h.sqs.QueueExists(name) {
+		return nil, queueDoesNotExist(name)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(QueueURL(name))}, nil
+}
+
+func (h *Handler) listQueues(_ context.Context, input *sqs.ListQueuesInput,
+	_ ...func(*sqs.Options)) (*sqs.ListQueuesOutput, error) {
+	var output sqs.ListQueuesOutput
+	for _, name := range h.sqs.QueueNames() {
+		if strings.HasPrefix(name, aws.ToString(input.QueueNamePrefix)) {
+			output.QueueUrls = append(output.QueueUrls, QueueURL(name))
+		}
+	}
+	return &output, nil
+}
+
+func (h *Handler) deleteQueue(_ context.Context, input *sqs.DeleteQueueInput,
+	_ ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error) {
+	h.sqs.DeleteQueue(aws.ToString(input.QueueUrl))
+	return &sqs.DeleteQueueOutput{}, nil
+}
+
+func (h *Handler) purgeQueue(_ context.Context, input *sqs.PurgeQueueInput,
+	_ ...func(*sqs.Options)) (*sqs.PurgeQueueOutput, error) {
+	h.sqs.DeleteQueue(aws.ToString(input.QueueUrl))
+	h.sqs.CreateQueue(aws.ToString(input.QueueUrl))
+	return &sqs.PurgeQueueOutput{}, nil
+}
@@ -0,0 +1,148 @@
+package sqstest
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func newTestClient(t *testing.T) *sqs.Client {
+	server := httptest.NewServer(NewHandler(New(), false))
+	t.Cleanup(server.Close)
+	return sqs.New(sqs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+}
+
+// TestHandler drives the handler through the SDK client.
+func TestHandler(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.TODO()
+
+	created, errCreate := client.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String("orders")})
+	if errCreate != nil {
+		t.Fatalf("create: %v", errCreate)
+	}
+	queueURL := created.QueueUrl
+
+	if got, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String("orders")}); err != nil ||
+		aws.ToString(got.QueueUrl) != aws.ToString(queueURL) {
+		t.Errorf("get queue url: %v %v", got, err)
+	}
+
+	_, errSend := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    queueURL,
+		MessageBody: aws.String("hello"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"traceparent": stringAttribute("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"),
+			"bin":         {DataType: aws.String("Binary"), BinaryValue: []byte{0, 1, 2}},
+		},
+	})
+	if errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	batch, errBatch := client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: queueURL,
+		Entries: []types.SendMessageBatchRequestEntry{
+			{Id: aws.String("a"), MessageBody: aws.String("second")},
+			{Id: aws.String("b"), MessageBody: aws.String("")},
+		},
+	})
+	if errBatch != nil {
+		t.Fatalf("send batch: %v", errBatch)
+	}
+	if len(batch.Successful) != 1 || len(batch.Failed) != 1 {
+		t.Errorf("unexpected batch result: successful=%d failed=%d", len(batch.Successful), len(batch.Failed))
+	}
+
+	out, errReceive := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:                    queueURL,
+		MaxNumberOfMessages:         10,
+		MessageAttributeNames:       []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{"SentTimestamp"},
+	})
+	if errReceive != nil {
+		t.Fatalf("receive: %v", errReceive)
+	}
+	if len(out.Messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(out.Messages))
+	}
+	first := out.Messages[0]
+	if aws.ToString(first.Body) != "hello" {
+		t.Errorf("unexpected body: %q", aws.ToString(first.Body))
+	}
+	if bin := first.MessageAttributes["bin"].BinaryValue; string(bin) != "\x00\x01\x02" {
+		t.Errorf("unexpected binary attribute: %v", bin)
+	}
+	if first.Attributes["SentTimestamp"] == "" {
+		t.Errorf("missing SentTimestamp: %v", first.Attributes)
+	}
+
+	_, errVisibility := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          queueURL,
+		ReceiptHandle:     out.Messages[1].ReceiptHandle,
+		VisibilityTimeout: 60,
+	})
+	if errVisibility != nil {
+		t.Errorf("change visibility: %v", errVisibility)
+	}
+
+	deleted, errDelete := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: queueURL,
+		Entries: []types.DeleteMessageBatchRequestEntry{
+			{Id: aws.String("0"), ReceiptHandle: out.Messages[0].ReceiptHandle},
+			{Id: aws.String("1"), ReceiptHandle: out.Messages[1].ReceiptHandle},
+			{Id: aws.String("2"), ReceiptHandle: aws.String("bogus")},
+		},
+	})
+	if errDelete != nil {
+		t.Fatalf("delete batch: %v", errDelete)
+	}
+	if len(deleted.Successful) != 2 || len(deleted.Failed) != 1 {
+		t.Errorf("unexpected delete result: successful=%d failed=%d", len(deleted.Successful), len(deleted.Failed))
+	}
+
+	list, errList := client.ListQueues(ctx, &sqs.ListQueuesInput{})
+	if errList != nil || len(list.QueueUrls) != 1 {
+		t.Errorf("list queues: %v %v", list, errList)
+	}
+}
+
+// TestHandlerErrors verifies errors decode into SDK error types.
+func TestHandlerErrors(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.TODO()
+
+	_, errMissing := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(QueueURL("missing")),
+		MessageBody: aws.String("hello"),
+	})
+	var notFound *types.QueueDoesNotExist
+	if !errors.As(errMissing, &notFound) {
+		t.Errorf("expected QueueDoesNotExist, got %v", errMissing)
+	}
+
+	client.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: aws.String("orders")})
+
+	_, errEmpty := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(QueueURL("orders"))})
+	if errEmpty == nil {
+		t.Errorf("expected error for empty batch")
+	}
+
+	_, errHandle := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(QueueURL("orders")),
+		ReceiptHandle: aws.String("bogus"),
+	})
+	var invalid *types.ReceiptHandleIsInvalid
+	if !errors.As(errHandle, &invalid) {
+		t.Errorf("expected ReceiptHandleIsInvalid, got %v", errHandle)
+	}
+}
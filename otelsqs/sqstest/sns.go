@@ -0,0 +1,142 @@
+package sqstest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// TopicARN returns a topic ARN for name, in the format used by SNS.
+func TopicARN(name string) string {
+	return "arn:aws:sns:us-east-1:000000000000:" + name
+}
+
+// SNS is an in-memory SNS service delivering messages to queues of an SQS fake.
+// It is safe for concurrent use.
+type SNS struct {
+	sqs           *SQS
+	mutex         sync.Mutex
+	subscriptions map[string][]subscription
+	sequence      int
+}
+
+type subscription struct {
+	queueURL string
+	raw      bool
+}
+
+// NewSNS creates an in-memory SNS service delivering to queues in s.
+func NewSNS(s *SQS) *SNS {
+	return &SNS{sqs: s, subscriptions: map[string][]subscription{}}
+}
+
+// Subscribe delivers messages published to topicARN into queueURL.
+// With raw delivery, the message is delivered as is, with message attributes.
+// Otherwise the message is wrapped in the JSON notification envelope, which
+// carries message attributes in the body.
+func (s *SNS) Subscribe(topicARN, queueURL string, raw bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscriptions[topicARN] = append(s.subscriptions[topicARN], subscription{queueURL: queueURL, raw: raw})
+}
+
+// Publish delivers the message to every queue subscribed to the topic.
+// It returns the first delivery error, if any.
+func (s *SNS) Publish(ctx context.Context, input *sns.PublishInput,
+	_ ...func(*sns.Options)) (*sns.PublishOutput, error) {
+
+	topic := aws.ToString(input.TopicArn)
+
+	s.mutex.Lock()
+	s.sequence++
+	id := fmt.Sprintf("00000000-0000-0000-0001-%012d", s.sequence)
+	subscriptions := s.subscriptions[topic]
+	s.mutex.Unlock()
+
+	for _, sub := range subscriptions {
+		send := &sqs.SendMessageInput{QueueUrl: aws.String(sub.queueURL)}
+		if sub.raw {
+			send.MessageBody = input.Message
+			send.MessageAttributes = sqsAttributes(input.MessageAttributes)
+		} else {
+			body, err := notification(id, topic, input)
+			if err != nil {
+				return nil, err
+			}
+			send.MessageBody = aws.String(body)
+		}
+		if _, err := s.sqs.SendMessage(ctx, send); err != nil {
+			return nil, fmt.Errorf("deliver to %s: %w", sub.queueURL, err)
+		}
+	}
+
+	return &sns.PublishOutput{MessageId: aws.String(id)}, nil
+}
+
+// sqsAttributes converts SNS message attributes for raw delivery.
+func sqsAttributes(attributes map[string]snstypes.MessageAttributeValue) map[string]types.MessageAttributeValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+	converted := map[string]types.MessageAttributeValue{}
+	for name, value := range attributes {
+		converted[name] = types.MessageAttributeValue{
+			DataType:    value.DataType,
+			StringValue: value.StringValue,
+			BinaryValue: value.BinaryValue,
+		}
+	}
+	return converted
+}
+
+type notificationAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+type notificationEnvelope struct {
+	Type              string                           `json:"Type"`
+	MessageID         string                           `json:"MessageId"`
+	TopicArn          string                           `json:"TopicArn"`
+	Subject           string                           `json:"Subject,omitempty"`
+	Message           string                           `json:"Message"`
+	Timestamp         string                           `json:"Timestamp"`
+	MessageAttributes map[string]notificationAttribute `json:"MessageAttributes,omitempty"`
+}
+
+// notification builds the JSON envelope used by non-raw delivery.
+// Binary attribute values are base64 encoded, like SNS does.
+func notification(id, topic string, input *sns.PublishInput) (string, error) {
+	envelope := notificationEnvelope{
+		Type:      "Notification",
+		MessageID: id,
+		TopicArn:  topic,
+		Subject:   aws.ToString(input.Subject),
+		Message:   aws.ToString(input.Message),
+		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	if len(input.MessageAttributes) > 0 {
+		envelope.MessageAttributes = map[string]notificationAttribute{}
+	}
+	for name, value := range input.MessageAttributes {
+		text := aws.ToString(value.StringValue)
+		if value.StringValue == nil {
+			text = base64.StdEncoding.EncodeToString(value.BinaryValue)
+		}
+		envelope.MessageAttributes[name] = notificationAttribute{
+			Type:  aws.ToString(value.DataType),
+			Value: text,
+		}
+	}
+	data, err := json.Marshal(envelope)
+	return string(data), err
+}
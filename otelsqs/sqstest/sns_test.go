@@ -0,0 +1,112 @@
+package sqstest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsns"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestFanOut verifies publish -> fan-out -> consume keeps the trace,
+// with both raw and non-raw delivery.
+func TestFanOut(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	propagator := propagation.TraceContext{}
+
+	fake := New()
+	topics := NewSNS(fake)
+
+	topic := TopicARN("orders")
+	rawQueue := QueueURL("orders-raw")
+	envelopeQueue := QueueURL("orders-envelope")
+	topics.Subscribe(topic, rawQueue, true)
+	topics.Subscribe(topic, envelopeQueue, false)
+
+	ctx, span := provider.Tracer("test").Start(context.TODO(), "publish")
+	defer span.End()
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(topic),
+		Message:  aws.String("hello"),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"bin": {DataType: aws.String("Binary"), BinaryValue: []byte{0, 1, 2}},
+		},
+	}
+	if err := otelsns.NewCarrier(otelsns.WithPropagator(propagator)).Inject(ctx, input.MessageAttributes); err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	if _, err := topics.Publish(ctx, input); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	consumer := otelsqs.NewCarrier(otelsqs.WithPropagator(propagator), otelsqs.WithBodyFallback(true))
+
+	for _, queueURL := range []string{rawQueue, envelopeQueue} {
+		out, err := fake.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(queueURL),
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			t.Fatalf("%s: receive: %v", queueURL, err)
+		}
+		if len(out.Messages) != 1 {
+			t.Fatalf("%s: expected 1 message, got %d", queueURL, len(out.Messages))
+		}
+		ctxConsumer := consumer.ExtractMessage(context.TODO(), out.Messages[0])
+		traceID := trace.SpanContextFromContext(ctxConsumer).TraceID()
+		if traceID != span.SpanContext().TraceID() {
+			t.Errorf("%s: expected trace %s, got %s", queueURL, span.SpanContext().TraceID(), traceID)
+		}
+	}
+}
+
+// TestNotificationEnvelope verifies the body of non-raw delivery.
+func TestNotificationEnvelope(t *testing.T) {
+	fake := New()
+	topics := NewSNS(fake)
+	topic := TopicARN("orders")
+	topics.Subscribe(topic, testQueueURL, false)
+
+	_, err := topics.Publish(context.TODO(), &sns.PublishInput{
+		TopicArn: aws.String(topic),
+		Message:  aws.String("hello"),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"bin":    {DataType: aws.String("Binary"), BinaryValue: []byte("abc")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msgs := fake.Messages(testQueueURL)
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	if len(msgs[0].MessageAttributes) != 0 {
+		t.Errorf("non-raw delivery must not carry message attributes: %v", msgs[0].MessageAttributes)
+	}
+
+	var envelope notificationEnvelope
+	if errJSON := json.Unmarshal([]byte(aws.ToString(msgs[0].Body)), &envelope); errJSON != nil {
+		t.Fatalf("body is not JSON: %v", errJSON)
+	}
+	if envelope.Type != "Notification" || envelope.TopicArn != topic || envelope.Message != "hello" {
+		t.Errorf("unexpected envelope: %+v", envelope)
+	}
+	if attr := envelope.MessageAttributes["tenant"]; attr.Type != "String" || attr.Value != "acme" {
+		t.Errorf("unexpected string attribute: %+v", attr)
+	}
+	if attr := envelope.MessageAttributes["bin"]; attr.Type != "Binary" || attr.Value != "YWJj" {
+		t.Errorf("unexpected binary attribute: %+v", attr)
+	}
+}
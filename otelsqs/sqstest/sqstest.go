@@ -0,0 +1,530 @@
+/*
+Package sqstest implements in-memory SQS queues and SNS topics for tests.
+
+SQS follows the semantics that matter for trace propagation: visibility
+timeout, receive count, requested system and message attributes, attribute
+count and message size limits. SNS fans messages out to subscribed queues,
+with either raw or non-raw (JSON envelope) delivery.
+
+# Usage
+
+	fake := sqstest.New()
+	queueURL := sqstest.QueueURL("orders")
+
+	// producer
+	input := &sqs.SendMessageInput{QueueUrl: aws.String(queueURL), MessageBody: aws.String("hello")}
+	ctx, span, _ := otelsqs.NewCarrier().StartProducerSpan(ctx, input)
+	fake.SendMessage(ctx, input)
+	span.End()
+
+	// consumer
+	out, _ := fake.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+	    QueueUrl:              aws.String(queueURL),
+	    MessageAttributeNames: []string{"All"},
+	})
+	ctx = otelsqs.NewCarrier().ExtractMessage(ctx, out.Messages[0])
+*/
+package sqstest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+// SQS limits enforced by the fake.
+const (
+	MaxMessageAttributes = 10         // message attributes per message
+	MaxMessageSize       = 256 * 1024 // body plus message attributes, in bytes
+	MaxBatchEntries      = 10         // entries per batch request
+	MaxReceiveMessages   = 10         // messages per receive
+)
+
+// DefaultVisibilityTimeout is the visibility timeout for received messages,
+// unless ReceiveMessageInput.VisibilityTimeout is set.
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// QueueURL returns a queue URL for name, in the format used by SQS.
+func QueueURL(name string) string {
+	return "https://sqs.us-east-1.amazonaws.com/000000000000/" + name
+}
+
+// SQS is an in-memory SQS service.
+// Queues are created on first use, keyed by queue name, the last path
+// segment of the queue URL, so the URL host and account are irrelevant.
+// It is safe for concurrent use.
+type SQS struct {
+	mutex    sync.Mutex
+	queues   map[string][]*message
+	sequence int
+}
+
+type message struct {
+	msg          types.Message
+	systemAttrs  map[string]string
+	invisible    time.Time
+	receives     int
+	firstReceive time.Time
+}
+
+// queueName extracts the queue name from queueURL.
+func queueName(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
+}
+
+// New creates an in-memory SQS service.
+func New() *SQS {
+	return &SQS{queues: map[string][]*message{}}
+}
+
+// Messages returns the messages held in queue, either visible or in flight,
+// with all their attributes.
+func (s *SQS) Messages(queueURL string) []types.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var list []types.Message
+	for _, m := range s.queues[queueName(queueURL)] {
+		msg := m.msg
+		msg.Attributes = maps.Clone(m.systemAttrs)
+		list = append(list, msg)
+	}
+	return list
+}
+
+// invalidParameter creates the error returned by SQS for invalid requests.
+func invalidParameter(format string, a ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "InvalidParameterValue",
+		Message: fmt.Sprintf(format, a...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+// messageSize computes the message size as accounted by SQS:
+// body plus names, data types and values of message attributes.
+func messageSize(body string, attributes map[string]types.MessageAttributeValue) int {
+	size := len(body)
+	for name, value := range attributes {
+		size += len(name) + len(aws.ToString(value.DataType)) +
+			len(aws.ToString(value.StringValue)) + len(value.BinaryValue)
+	}
+	return size
+}
+
+// md5Hex computes the digest SQS returns for message bodies, checked by the SDK.
+func md5Hex(body string) string {
+	sum := md5.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// validate checks the message against SQS limits.
+func validate(body string, attributes map[string]types.MessageAttributeValue) error {
+	if body == "" {
+		return invalidParameter("message body must not be empty")
+	}
+	if len(attributes) > MaxMessageAttributes {
+		return invalidParameter("number of message attributes [%d] exceeds the allowed maximum [%d]",
+			len(attributes), MaxMessageAttributes)
+	}
+	for name, value := range attributes {
+		if value.DataType == nil {
+			return invalidParameter("message attribute '%s' must contain a non-empty data type", name)
+		}
+		if value.StringValue == nil && len(value.BinaryValue) == 0 {
+			return invalidParameter("message attribute '%s' must contain a non-empty value", name)
+		}
+	}
+	if size := messageSize(body, attributes); size > MaxMessageSize {
+		return invalidParameter("message must be shorter than %d bytes, got %d", MaxMessageSize, size)
+	}
+	return nil
+}
+
+// send appends a validated message to the queue.
+// s.mutex must be held.
+func (s *SQS) send(queueURL, body string, attributes map[string]types.MessageAttributeValue,
+	system map[string]types.MessageSystemAttributeValue, delaySeconds int32) string {
+
+	s.sequence++
+	id := fmt.Sprintf("00000000-0000-0000-0000-%012d", s.sequence)
+
+	now := time.Now()
+
+	systemAttrs := map[string]string{
+		string(types.MessageSystemAttributeNameSentTimestamp): strconv.FormatInt(now.UnixMilli(), 10),
+		string(types.MessageSystemAttributeNameSenderId):      "AIDAFAKESENDER",
+	}
+	if header, found := system[string(types.MessageSystemAttributeNameAWSTraceHeader)]; found {
+		systemAttrs[string(types.MessageSystemAttributeNameAWSTraceHeader)] = aws.ToString(header.StringValue)
+	}
+
+	s.queues[queueName(queueURL)] = append(s.queues[queueName(queueURL)], &message{
+		msg: types.Message{
+			MessageId:         aws.String(id),
+			Body:              aws.String(body),
+			MD5OfBody:         aws.String(md5Hex(body)),
+			MessageAttributes: maps.Clone(attributes),
+		},
+		systemAttrs: systemAttrs,
+		invisible:   now.Add(time.Duration(delaySeconds) * time.Second),
+	})
+
+	return id
+}
+
+// SendMessage appends a message to the queue.
+// It fails like SQS on empty body, too many attributes or oversized message.
+func (s *SQS) SendMessage(_ context.Context, input *sqs.SendMessageInput,
+	_ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+
+	body := aws.ToString(input.MessageBody)
+
+	if err := validate(body, input.MessageAttributes); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := s.send(aws.ToString(input.QueueUrl), body, input.MessageAttributes,
+		input.MessageSystemAttributes, input.DelaySeconds)
+
+	return &sqs.SendMessageOutput{
+		MessageId:        aws.String(id),
+		MD5OfMessageBody: aws.String(md5Hex(body)),
+	}, nil
+}
+
+// SendMessageBatch appends messages to the queue.
+// Invalid entries are reported as failed, while valid ones are sent.
+func (s *SQS) SendMessageBatch(_ context.Context, input *sqs.SendMessageBatchInput,
+	_ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+
+	if err := checkBatch(len(input.Entries)); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{}
+	total := 0
+	for _, entry := range input.Entries {
+		id := aws.ToString(entry.Id)
+		if ids[id] {
+			return nil, &types.BatchEntryIdsNotDistinct{Message: aws.String("duplicate entry id: " + id)}
+		}
+		ids[id] = true
+		total += messageSize(aws.ToString(entry.MessageBody), entry.MessageAttributes)
+	}
+	if total > MaxMessageSize {
+		return nil, &types.BatchRequestTooLong{Message: aws.String(
+			fmt.Sprintf("batch requests must be shorter than %d bytes, got %d", MaxMessageSize, total))}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var output sqs.SendMessageBatchOutput
+
+	for _, entry := range input.Entries {
+		body := aws.ToString(entry.MessageBody)
+		if err := validate(body, entry.MessageAttributes); err != nil {
+			output.Failed = append(output.Failed, failedEntry(entry.Id, "InvalidParameterValue", err))
+			continue
+		}
+		id := s.send(aws.ToString(input.QueueUrl), body, entry.MessageAttributes,
+			entry.MessageSystemAttributes, entry.DelaySeconds)
+		output.Successful = append(output.Successful, types.SendMessageBatchResultEntry{
+			Id:               entry.Id,
+			MessageId:        aws.String(id),
+			MD5OfMessageBody: aws.String(md5Hex(body)),
+		})
+	}
+
+	return &output, nil
+}
+
+// ReceiveMessage returns visible messages, making them invisible for the
+// visibility timeout. It waits up to WaitTimeSeconds for messages.
+// Like SQS, it returns only the requested system and message attributes.
+func (s *SQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput,
+	_ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+
+	if input.MaxNumberOfMessages < 0 || input.MaxNumberOfMessages > MaxReceiveMessages {
+		return nil, invalidParameter("MaxNumberOfMessages must be between 1 and %d", MaxReceiveMessages)
+	}
+
+	deadline := time.Now().Add(time.Duration(input.WaitTimeSeconds) * time.Second)
+
+	for {
+		if msgs := s.receive(input); len(msgs) > 0 || !time.Now().Before(deadline) {
+			return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *SQS) receive(input *sqs.ReceiveMessageInput) []types.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	maxMessages := max(int(input.MaxNumberOfMessages), 1)
+
+	visibility := DefaultVisibilityTimeout
+	if input.VisibilityTimeout > 0 {
+		visibility = time.Duration(input.VisibilityTimeout) * time.Second
+	}
+
+	systemNames := requestedSystemAttributes(input)
+
+	now := time.Now()
+
+	var msgs []types.Message
+	for _, m := range s.queues[queueName(aws.ToString(input.QueueUrl))] {
+		if len(msgs) == maxMessages {
+			break
+		}
+		if now.Before(m.invisible) {
+			continue
+		}
+
+		m.receives++
+		m.invisible = now.Add(visibility)
+		if m.firstReceive.IsZero() {
+			m.firstReceive = now
+		}
+		m.systemAttrs[string(types.MessageSystemAttributeNameApproximateReceiveCount)] = strconv.Itoa(m.receives)
+		m.systemAttrs[string(types.MessageSystemAttributeNameApproximateFirstReceiveTimestamp)] =
+			strconv.FormatInt(m.firstReceive.UnixMilli(), 10)
+
+		msg := m.msg
+		msg.ReceiptHandle = aws.String(fmt.Sprintf("%s#%d", aws.ToString(m.msg.MessageId), m.receives))
+		m.msg.ReceiptHandle = msg.ReceiptHandle
+		msg.Attributes = filterSystemAttributes(m.systemAttrs, systemNames)
+		msg.MessageAttributes = filterMessageAttributes(m.msg.MessageAttributes, input.MessageAttributeNames)
+
+		msgs = append(msgs, msg)
+	}
+
+	return msgs
+}
+
+// requestedSystemAttributes merges the deprecated AttributeNames with MessageSystemAttributeNames.
+func requestedSystemAttributes(input *sqs.ReceiveMessageInput) []string {
+	var names []string
+	for _, name := range input.AttributeNames {
+		names = append(names, string(name))
+	}
+	for _, name := range input.MessageSystemAttributeNames {
+		names = append(names, string(name))
+	}
+	return names
+}
+
+func filterSystemAttributes(attributes map[string]string, names []string) map[string]string {
+	if slices.Contains(names, "All") {
+		return maps.Clone(attributes)
+	}
+	filtered := map[string]string{}
+	for _, name := range names {
+		if value, found := attributes[name]; found {
+			filtered[name] = value
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return filtered
+}
+
+// filterMessageAttributes keeps attributes matching names, which may be
+// "All", ".*", exact names, or prefixes such as "otel.*".
+func filterMessageAttributes(attributes map[string]types.MessageAttributeValue,
+	names []string) map[string]types.MessageAttributeValue {
+
+	filtered := map[string]types.MessageAttributeValue{}
+	for attr, value := range attributes {
+		for _, name := range names {
+			prefix, wildcard := strings.CutSuffix(name, ".*")
+			if name == "All" || name == ".*" || name == attr || (wildcard && strings.HasPrefix(attr, prefix+".")) {
+				filtered[attr] = value
+				break
+			}
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return filtered
+}
+
+// find returns the index of the message with receipt handle, or -1.
+// s.mutex must be held.
+func (s *SQS) find(queueURL, receiptHandle string) int {
+	for i, m := range s.queues[queueName(queueURL)] {
+		if aws.ToString(m.msg.ReceiptHandle) == receiptHandle {
+			return i
+		}
+	}
+	return -1
+}
+
+func receiptHandleIsInvalid(receiptHandle *string) error {
+	return &types.ReceiptHandleIsInvalid{Message: aws.String("invalid receipt handle: " + aws.ToString(receiptHandle))}
+}
+
+// DeleteMessage removes the message with the receipt handle.
+// Only the receipt handle from the latest receive is valid.
+func (s *SQS) DeleteMessage(_ context.Context, input *sqs.DeleteMessageInput,
+	_ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	url := aws.ToString(input.QueueUrl)
+	i := s.find(url, aws.ToString(input.ReceiptHandle))
+	if i < 0 {
+		return nil, receiptHandleIsInvalid(input.ReceiptHandle)
+	}
+	s.queues[queueName(url)] = slices.Delete(s.queues[queueName(url)], i, i+1)
+
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// DeleteMessageBatch removes the messages with the receipt handles.
+// Invalid receipt handles are reported as failed entries.
+func (s *SQS) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput,
+	_ ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+
+	if err := checkBatch(len(input.Entries)); err != nil {
+		return nil, err
+	}
+
+	var output sqs.DeleteMessageBatchOutput
+
+	for _, entry := range input.Entries {
+		_, err := s.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      input.QueueUrl,
+			ReceiptHandle: entry.ReceiptHandle,
+		})
+		if err != nil {
+			output.Failed = append(output.Failed, failedEntry(entry.Id, "ReceiptHandleIsInvalid", err))
+			continue
+		}
+		output.Successful = append(output.Successful, types.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+
+	return &output, nil
+}
+
+// ChangeMessageVisibility changes the visibility timeout of the message with the receipt handle.
+func (s *SQS) ChangeMessageVisibility(_ context.Context, input *sqs.ChangeMessageVisibilityInput,
+	_ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	url := aws.ToString(input.QueueUrl)
+	i := s.find(url, aws.ToString(input.ReceiptHandle))
+	if i < 0 {
+		return nil, receiptHandleIsInvalid(input.ReceiptHandle)
+	}
+	s.queues[queueName(url)][i].invisible = time.Now().Add(time.Duration(input.VisibilityTimeout) * time.Second)
+
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+// ChangeMessageVisibilityBatch changes the visibility timeout of the messages with the receipt handles.
+// Invalid receipt handles are reported as failed entries.
+func (s *SQS) ChangeMessageVisibilityBatch(ctx context.Context, input *sqs.ChangeMessageVisibilityBatchInput,
+	_ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+
+	if err := checkBatch(len(input.Entries)); err != nil {
+		return nil, err
+	}
+
+	var output sqs.ChangeMessageVisibilityBatchOutput
+
+	for _, entry := range input.Entries {
+		_, err := s.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          input.QueueUrl,
+			ReceiptHandle:     entry.ReceiptHandle,
+			VisibilityTimeout: entry.VisibilityTimeout,
+		})
+		if err != nil {
+			output.Failed = append(output.Failed, failedEntry(entry.Id, "ReceiptHandleIsInvalid", err))
+			continue
+		}
+		output.Successful = append(output.Successful, types.ChangeMessageVisibilityBatchResultEntry{Id: entry.Id})
+	}
+
+	return &output, nil
+}
+
+// checkBatch checks the number of entries in a batch request.
+func checkBatch(entries int) error {
+	if entries == 0 {
+		return &types.EmptyBatchRequest{Message: aws.String("batch request must contain at least one entry")}
+	}
+	if entries > MaxBatchEntries {
+		return &types.TooManyEntriesInBatchRequest{Message: aws.String(
+			fmt.Sprintf("maximum number of entries per request is %d", MaxBatchEntries))}
+	}
+	return nil
+}
+
+func failedEntry(id *string, code string, err error) types.BatchResultErrorEntry {
+	return types.BatchResultErrorEntry{
+		Id:          id,
+		Code:        aws.String(code),
+		Message:     aws.String(err.Error()),
+		SenderFault: true,
+	}
+}
+
+// CreateQueue creates the queue if it does not exist.
+// Queues are also created on first use by other methods.
+func (s *SQS) CreateQueue(queueURL string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	name := queueName(queueURL)
+	if _, found := s.queues[name]; !found {
+		s.queues[name] = nil
+	}
+}
+
+// DeleteQueue removes the queue and its messages.
+func (s *SQS) DeleteQueue(queueURL string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.queues, queueName(queueURL))
+}
+
+// QueueExists reports whether the queue was created, either explicitly or on first use.
+func (s *SQS) QueueExists(queueURL string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, found := s.queues[queueName(queueURL)]
+	return found
+}
+
+// QueueNames lists queue names in lexical order.
+func (s *SQS) QueueNames() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Sorted(maps.Keys(s.queues))
+}
@@ -0,0 +1,241 @@
+package sqstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+var testQueueURL = QueueURL("orders")
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func send(t *testing.T, s *SQS, body string, attributes map[string]types.MessageAttributeValue) {
+	t.Helper()
+	_, err := s.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl:          aws.String(testQueueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: attributes,
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func receive(t *testing.T, s *SQS, input *sqs.ReceiveMessageInput) []types.Message {
+	t.Helper()
+	input.QueueUrl = aws.String(testQueueURL)
+	out, err := s.ReceiveMessage(context.TODO(), input)
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return out.Messages
+}
+
+// TestVisibility verifies received messages stay invisible until deleted or made visible again.
+func TestVisibility(t *testing.T) {
+	s := New()
+	send(t, s, "hello", nil)
+
+	msgs := receive(t, s, &sqs.ReceiveMessageInput{MessageSystemAttributeNames: []types.MessageSystemAttributeName{"All"}})
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	if count := msgs[0].Attributes["ApproximateReceiveCount"]; count != "1" {
+		t.Errorf("expected receive count 1, got %q", count)
+	}
+
+	if again := receive(t, s, &sqs.ReceiveMessageInput{}); len(again) != 0 {
+		t.Errorf("expected in-flight message to be invisible, got %d messages", len(again))
+	}
+
+	_, errChange := s.ChangeMessageVisibility(context.TODO(), &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(testQueueURL),
+		ReceiptHandle:     msgs[0].ReceiptHandle,
+		VisibilityTimeout: 0,
+	})
+	if errChange != nil {
+		t.Fatalf("change visibility: %v", errChange)
+	}
+
+	redelivered := receive(t, s, &sqs.ReceiveMessageInput{AttributeNames: []types.QueueAttributeName{"ApproximateReceiveCount"}})
+	if len(redelivered) != 1 {
+		t.Fatalf("expected redelivery, got %d messages", len(redelivered))
+	}
+	if count := redelivered[0].Attributes["ApproximateReceiveCount"]; count != "2" {
+		t.Errorf("expected receive count 2, got %q", count)
+	}
+
+	// stale receipt handle
+	_, errStale := s.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(testQueueURL),
+		ReceiptHandle: msgs[0].ReceiptHandle,
+	})
+	var invalid *types.ReceiptHandleIsInvalid
+	if !errors.As(errStale, &invalid) {
+		t.Errorf("expected ReceiptHandleIsInvalid for stale handle, got %v", errStale)
+	}
+
+	_, errDelete := s.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(testQueueURL),
+		ReceiptHandle: redelivered[0].ReceiptHandle,
+	})
+	if errDelete != nil {
+		t.Errorf("delete: %v", errDelete)
+	}
+	if left := s.Messages(testQueueURL); len(left) != 0 {
+		t.Errorf("expected empty queue, got %d messages", len(left))
+	}
+}
+
+// TestRequestedAttributes verifies only requested attributes are returned.
+func TestRequestedAttributes(t *testing.T) {
+	s := New()
+	send(t, s, "hello", map[string]types.MessageAttributeValue{
+		"otel.traceparent": stringAttribute("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"),
+		"tenant":           stringAttribute("acme"),
+	})
+
+	testCases := []struct {
+		names    []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"All"}, []string{"otel.traceparent", "tenant"}},
+		{[]string{".*"}, []string{"otel.traceparent", "tenant"}},
+		{[]string{"otel.*"}, []string{"otel.traceparent"}},
+		{[]string{"tenant"}, []string{"tenant"}},
+		{[]string{"missing"}, nil},
+	}
+
+	for _, data := range testCases {
+		msgs := receive(t, s, &sqs.ReceiveMessageInput{MessageAttributeNames: data.names})
+		if len(msgs) != 1 {
+			t.Fatalf("names=%v: expected 1 message, got %d", data.names, len(msgs))
+		}
+		if len(msgs[0].MessageAttributes) != len(data.expected) {
+			t.Errorf("names=%v: expected attributes %v, got %v", data.names, data.expected, msgs[0].MessageAttributes)
+		}
+		for _, name := range data.expected {
+			if _, found := msgs[0].MessageAttributes[name]; !found {
+				t.Errorf("names=%v: missing attribute %s", data.names, name)
+			}
+		}
+		if msgs[0].Attributes != nil {
+			t.Errorf("names=%v: unexpected system attributes: %v", data.names, msgs[0].Attributes)
+		}
+		// make visible for next case
+		s.ChangeMessageVisibility(context.TODO(), &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:      aws.String(testQueueURL),
+			ReceiptHandle: msgs[0].ReceiptHandle,
+		})
+	}
+}
+
+// TestLimits verifies messages breaking SQS limits are refused.
+func TestLimits(t *testing.T) {
+	tooMany := map[string]types.MessageAttributeValue{}
+	for i := range MaxMessageAttributes + 1 {
+		tooMany[fmt.Sprintf("attr%d", i)] = stringAttribute("value")
+	}
+
+	testCases := []struct {
+		name       string
+		body       string
+		attributes map[string]types.MessageAttributeValue
+		valid      bool
+	}{
+		{"small", "hello", nil, true},
+		{"empty body", "", nil, false},
+		{"max size", strings.Repeat("x", MaxMessageSize), nil, true},
+		{"oversized body", strings.Repeat("x", MaxMessageSize+1), nil, false},
+		{"oversized attributes", strings.Repeat("x", MaxMessageSize-10),
+			map[string]types.MessageAttributeValue{"key": stringAttribute("value")}, false},
+		{"too many attributes", "hello", tooMany, false},
+		{"missing data type", "hello",
+			map[string]types.MessageAttributeValue{"key": {StringValue: aws.String("value")}}, false},
+	}
+
+	for _, data := range testCases {
+		_, err := New().SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl:          aws.String(testQueueURL),
+			MessageBody:       aws.String(data.body),
+			MessageAttributes: data.attributes,
+		})
+		if data.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", data.name, err)
+		}
+		var apiErr smithy.APIError
+		if !data.valid && (!errors.As(err, &apiErr) || apiErr.ErrorCode() != "InvalidParameterValue") {
+			t.Errorf("%s: expected InvalidParameterValue, got %v", data.name, err)
+		}
+	}
+}
+
+// TestSendMessageBatch verifies valid entries are sent and invalid ones reported.
+func TestSendMessageBatch(t *testing.T) {
+	s := New()
+
+	out, err := s.SendMessageBatch(context.TODO(), &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(testQueueURL),
+		Entries: []types.SendMessageBatchRequestEntry{
+			{Id: aws.String("a"), MessageBody: aws.String("first")},
+			{Id: aws.String("b"), MessageBody: aws.String("")},
+			{Id: aws.String("c"), MessageBody: aws.String("third")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("send batch: %v", err)
+	}
+	if len(out.Successful) != 2 || len(out.Failed) != 1 || aws.ToString(out.Failed[0].Id) != "b" {
+		t.Errorf("unexpected result: successful=%d failed=%v", len(out.Successful), out.Failed)
+	}
+	if left := s.Messages(testQueueURL); len(left) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(left))
+	}
+
+	msgs := receive(t, s, &sqs.ReceiveMessageInput{MaxNumberOfMessages: 10})
+	if len(msgs) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(msgs))
+	}
+
+	_, errDup := s.SendMessageBatch(context.TODO(), &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(testQueueURL),
+		Entries: []types.SendMessageBatchRequestEntry{
+			{Id: aws.String("a"), MessageBody: aws.String("first")},
+			{Id: aws.String("a"), MessageBody: aws.String("second")},
+		},
+	})
+	var dup *types.BatchEntryIdsNotDistinct
+	if !errors.As(errDup, &dup) {
+		t.Errorf("expected BatchEntryIdsNotDistinct, got %v", errDup)
+	}
+
+	_, errEmpty := s.SendMessageBatch(context.TODO(), &sqs.SendMessageBatchInput{QueueUrl: aws.String(testQueueURL)})
+	var empty *types.EmptyBatchRequest
+	if !errors.As(errEmpty, &empty) {
+		t.Errorf("expected EmptyBatchRequest, got %v", errEmpty)
+	}
+}
+
+// TestReceiveWait verifies ReceiveMessage honors context cancellation while long polling.
+func TestReceiveWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err := New().ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:        aws.String(testQueueURL),
+		WaitTimeSeconds: 20,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package otelsqs
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/internal/attrvalid"
+)
+
+// validateAttribute checks a message attribute against SQS rules.
+func validateAttribute(name string, value types.MessageAttributeValue) error {
+	if err := attrvalid.Check(name, value.DataType, value.StringValue, value.BinaryValue); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAttribute, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package sqslistener
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults for Backoff.
+const (
+	DefaultBackoffInitial    = 200 * time.Millisecond
+	DefaultBackoffMax        = 20 * time.Second
+	DefaultBackoffMultiplier = 2.0
+	DefaultBackoffJitter     = 0.2
+)
+
+// Backoff is an exponential backoff policy with jitter, applied by Listener
+// to retries of failed receive and delete calls.
+// Zero fields take their defaults.
+type Backoff struct {
+	// Initial is the delay before the first retry. Defaults to 200ms.
+	Initial time.Duration
+
+	// Max caps the delay. Defaults to 20s.
+	Max time.Duration
+
+	// Multiplier grows the delay after each consecutive failure. Defaults to 2.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction, either way,
+	// to spread retries from concurrent receivers. Defaults to 0.2; negative disables.
+	// Values above 1 are clamped to 1, so that delays are never negative.
+	Jitter float64
+}
+
+// Delay returns the delay before retry attempt, counted from zero
+// for the first retry after a success.
+func (b Backoff) Delay(attempt int) time.Duration {
+	initial := b.Initial
+	if initial <= 0 {
+		initial = DefaultBackoffInitial
+	}
+	maxDelay := b.Max
+	if maxDelay <= 0 {
+		maxDelay = DefaultBackoffMax
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultBackoffMultiplier
+	}
+	jitter := b.Jitter
+	if jitter == 0 {
+		jitter = DefaultBackoffJitter
+	}
+	jitter = min(jitter, 1)
+
+	delay := min(float64(initial)*math.Pow(multiplier, float64(attempt)), float64(maxDelay))
+
+	if jitter > 0 {
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+
+	return min(time.Duration(delay), maxDelay)
+}
+
+// retrier tracks consecutive failures of a call, for Backoff.
+type retrier struct {
+	policy  Backoff
+	attempt int
+}
+
+// next returns the delay before the next retry, growing it for the following one.
+func (r *retrier) next() time.Duration {
+	delay := r.policy.Delay(r.attempt)
+	r.attempt++
+	return delay
+}
+
+// reset restarts the backoff after a success.
+func (r *retrier) reset() {
+	r.attempt = 0
+}
@@ -0,0 +1,163 @@
+package sqslistener
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: -1}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+
+	for attempt, want := range expected {
+		if got := b.Delay(attempt); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: time.Minute, Jitter: 0.5}
+
+	for range 100 {
+		if got := b.Delay(0); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Errorf("delay out of jitter range: %v", got)
+		}
+	}
+
+	// jitter never exceeds the cap
+	capped := Backoff{Initial: time.Second, Max: time.Second, Jitter: 0.5}
+	for range 100 {
+		if got := capped.Delay(5); got > time.Second {
+			t.Errorf("delay above max: %v", got)
+		}
+	}
+
+	// jitter above 1 is clamped, never yielding negative delays
+	wide := Backoff{Initial: time.Second, Max: time.Minute, Jitter: 5}
+	for range 100 {
+		if got := wide.Delay(0); got < 0 || got > 2*time.Second {
+			t.Errorf("delay out of clamped jitter range: %v", got)
+		}
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	var b Backoff
+	if got := b.Delay(100); got > DefaultBackoffMax {
+		t.Errorf("expected delay capped at %v, got %v", DefaultBackoffMax, got)
+	}
+	if got := b.Delay(0); got < DefaultBackoffInitial*8/10 || got > DefaultBackoffInitial*12/10 {
+		t.Errorf("unexpected initial delay: %v", got)
+	}
+}
+
+func TestRetrierReset(t *testing.T) {
+	r := retrier{policy: Backoff{Initial: time.Millisecond, Jitter: -1}}
+	r.next()
+	r.next()
+	if got := r.next(); got != 4*time.Millisecond {
+		t.Errorf("expected third delay 4ms, got %v", got)
+	}
+	r.reset()
+	if got := r.next(); got != time.Millisecond {
+		t.Errorf("expected delay reset to 1ms, got %v", got)
+	}
+}
+
+// throttledClient fails the first receives with a throttling error.
+type throttledClient struct {
+	*sqstest.SQS
+	failures atomic.Int32
+}
+
+func (c *throttledClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if c.failures.Add(-1) >= 0 {
+		return nil, &smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"}
+	}
+	return c.SQS.ReceiveMessage(ctx, input, optFns...)
+}
+
+func TestListenerBackoff(t *testing.T) {
+	client := &throttledClient{SQS: sqstest.New()}
+	client.failures.Store(2)
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("ok")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithMeterProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			return ResultAck
+		}),
+		Backoff: Backoff{Initial: 10 * time.Millisecond},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	// recovers from throttling within milliseconds, not a fixed cooldown
+
+	eventually(t, func() bool { return handled.Load() == 1 })
+
+	cancel()
+	<-done
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.TODO(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+
+	var backoffs uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != otelsqs.MetricBackoff {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+				if v, _ := dp.Attributes.Value(attribute.Key("error.type")); v.AsString() == "RequestThrottled" {
+					backoffs += dp.Count
+				}
+			}
+		}
+	}
+	if backoffs != 2 {
+		t.Errorf("expected 2 throttling backoffs recorded, got %d", backoffs)
+	}
+}
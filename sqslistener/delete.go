@@ -0,0 +1,304 @@
+package sqslistener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Defaults for batch deletes.
+const (
+	DefaultDeleteBatchSize     = 10 // maximum entries accepted by DeleteMessageBatch
+	DefaultDeleteBatchInterval = 200 * time.Millisecond
+	DefaultDeleteMaxPending    = 1000
+)
+
+// errPendingLimit gives up deleting the oldest pending message when
+// Listener.DeleteMaxPending is reached.
+var errPendingLimit = errors.New("too many pending deletes")
+
+// deleteRequest asks for the deletion of an acknowledged message.
+// The deleter records the outcome on span, then ends it.
+type deleteRequest struct {
+	msg    types.Message
+	span   trace.Span
+	queued time.Time
+}
+
+// deleter deletes acknowledged messages with DeleteMessageBatch.
+// Requests are queued without blocking, so workers never wait for deletes;
+// the queue is bounded by giving up the oldest requests, see Listener.DeleteMaxPending.
+type deleter struct {
+	listener   *Listener
+	consumer   *otelsqs.SqsCarrierAttributes
+	size       int
+	interval   time.Duration
+	maxPending int
+	retry      retrier
+
+	mutex    sync.Mutex
+	pending  []deleteRequest // oldest first
+	flushing int             // leading pending requests being flushed
+	closed   bool
+	wake     chan struct{}
+}
+
+func (l *Listener) newDeleter(consumer *otelsqs.SqsCarrierAttributes) *deleter {
+	size := l.DeleteBatchSize
+	if size < 1 || size > DefaultDeleteBatchSize {
+		size = DefaultDeleteBatchSize
+	}
+	interval := l.DeleteBatchInterval
+	if interval <= 0 {
+		interval = DefaultDeleteBatchInterval
+	}
+	maxPending := l.DeleteMaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultDeleteMaxPending
+	}
+	return &deleter{
+		listener:   l,
+		consumer:   consumer,
+		size:       size,
+		interval:   interval,
+		maxPending: max(maxPending, size),
+		retry:      retrier{policy: l.Backoff},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// add queues msg for deletion. It does not block.
+// When maxPending requests are queued, the oldest one not being flushed is
+// given up, leaving its message for redelivery.
+func (d *deleter) add(ctx context.Context, msg types.Message, span trace.Span) {
+	var dropped []deleteRequest
+
+	d.mutex.Lock()
+	if len(d.pending) >= d.maxPending && d.flushing < len(d.pending) {
+		dropped = append(dropped, d.pending[d.flushing])
+		d.pending = slices.Delete(d.pending, d.flushing, d.flushing+1)
+	}
+	d.pending = append(d.pending, deleteRequest{msg: msg, span: span, queued: time.Now()})
+	d.mutex.Unlock()
+
+	d.signal()
+
+	for _, req := range dropped {
+		log.Printf("Listener.delete: MessageId: %s - %v: leaving it for redelivery",
+			aws.ToString(req.msg.MessageId), errPendingLimit)
+	}
+	if len(dropped) > 0 {
+		d.fail(ctx, dropped, errPendingLimit)
+	}
+}
+
+// close tells run to flush pending requests and return.
+// No requests may be added after close.
+func (d *deleter) close() {
+	d.mutex.Lock()
+	d.closed = true
+	d.mutex.Unlock()
+	d.signal()
+}
+
+// signal wakes run up.
+func (d *deleter) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the batch of oldest pending requests, up to the batch size,
+// the time its oldest request was queued, and whether the deleter is closed.
+// The batch is marked as being flushed until done or release is called.
+func (d *deleter) next() ([]deleteRequest, time.Time, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	batch := d.pending[:min(len(d.pending), d.size)]
+	var oldest time.Time
+	if len(batch) > 0 {
+		oldest = batch[0].queued
+	}
+	d.flushing = len(batch)
+	return slices.Clone(batch), oldest, d.closed
+}
+
+// done removes the first count pending requests, once settled.
+func (d *deleter) done(count int) {
+	d.mutex.Lock()
+	d.pending = slices.Delete(d.pending, 0, count)
+	d.flushing = 0
+	d.mutex.Unlock()
+}
+
+// release keeps the batch returned by next pending, for a later retry,
+// allowing add to give its requests up.
+func (d *deleter) release() {
+	d.mutex.Lock()
+	d.flushing = 0
+	d.mutex.Unlock()
+}
+
+// run deletes pending requests in batches, flushing when a batch is full or
+// when its oldest request waited for the interval. When the batch call fails,
+// for instance when throttled, the whole batch is retried with
+// DeleteMessageBatch after a backoff, while new requests keep queueing.
+// After close, pending requests are flushed right away, cutting a backoff
+// short, and batches failing then are given up, leaving their messages for
+// redelivery. run returns once no requests are pending after close.
+func (d *deleter) run(ctx context.Context) {
+
+	const me = "Listener.delete"
+
+	l := d.listener
+
+	timer := time.NewTimer(d.interval)
+	timer.Stop()
+	defer timer.Stop()
+
+	var retryAt time.Time // backing off until retryAt
+
+	for {
+		batch, oldest, closed := d.next()
+		if closed && len(batch) == 0 {
+			return
+		}
+
+		now := time.Now()
+		backingOff := !closed && now.Before(retryAt)
+		due := len(batch) == d.size || (len(batch) > 0 && (closed || now.Sub(oldest) >= d.interval))
+
+		if due && !backingOff {
+			errBatch := d.flush(ctx, batch)
+			switch {
+			case errBatch == nil:
+				d.retry.reset()
+				retryAt = time.Time{}
+			case closed:
+				log.Printf("%s: sqs.DeleteMessageBatch: %d messages: error: %v, stopping: leaving them for redelivery",
+					me, len(batch), errBatch)
+				d.fail(ctx, batch, errBatch)
+			default:
+				delay := d.retry.next()
+				log.Printf("%s: sqs.DeleteMessageBatch: %d messages: error: %v, retrying in %v",
+					me, len(batch), errBatch, delay)
+				d.consumer.RecordBackoff(ctx, l.QueueURL, "delete", delay, errBatch)
+				retryAt = now.Add(delay)
+				d.release()
+				continue // batch kept pending for retry
+			}
+			d.done(len(batch))
+			continue
+		}
+
+		d.release()
+
+		switch {
+		case backingOff:
+			timer.Reset(retryAt.Sub(now))
+		case len(batch) > 0:
+			timer.Reset(d.interval - now.Sub(oldest))
+		}
+
+		select {
+		case <-d.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// flush deletes batch with DeleteMessageBatch, recording the outcome on
+// each span and ending it. Entries failed by the batch call are retried one
+// by one with DeleteMessage. When the call itself fails, flush returns its
+// error, leaving the spans untouched, so that the batch can be retried.
+func (d *deleter) flush(ctx context.Context, batch []deleteRequest) error {
+
+	const me = "Listener.delete"
+
+	l := d.listener
+
+	input := &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(l.QueueURL)}
+	for i, req := range batch {
+		input.Entries = append(input.Entries, types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: req.msg.ReceiptHandle,
+		})
+	}
+
+	out, errBatch := l.Client.DeleteMessageBatch(ctx, input)
+	if errBatch != nil {
+		return errBatch
+	}
+
+	failed := map[int]string{}
+	for _, entry := range out.Failed {
+		i, errID := strconv.Atoi(aws.ToString(entry.Id))
+		if errID != nil || i < 0 || i >= len(batch) {
+			log.Printf("%s: sqs.DeleteMessageBatch: unexpected entry id: %s", me, aws.ToString(entry.Id))
+			continue
+		}
+		failed[i] = fmt.Sprintf("%s: %s", aws.ToString(entry.Code), aws.ToString(entry.Message))
+	}
+
+	batchAttrs := trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(batch)))
+
+	d.consumer.RecordDeleted(ctx, l.QueueURL, len(batch)-len(failed), nil)
+
+	for i, req := range batch {
+		reason, retry := failed[i]
+		if !retry {
+			req.span.AddEvent("message.deleted", batchAttrs)
+			req.span.End()
+			continue
+		}
+
+		req.span.AddEvent("message.delete.failed", batchAttrs,
+			trace.WithAttributes(attribute.String("error.message", reason)))
+
+		_, errDelete := l.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(l.QueueURL),
+			ReceiptHandle: req.msg.ReceiptHandle,
+		})
+		d.consumer.RecordDeleted(ctx, l.QueueURL, 1, errDelete)
+		if errDelete != nil {
+			m := fmt.Sprintf("%s: MessageId: %s - sqs.DeleteMessage: error: %v",
+				me, aws.ToString(req.msg.MessageId), errDelete)
+			log.Print(m)
+			req.span.AddEvent("message.delete.failed",
+				trace.WithAttributes(attribute.String("error.message", errDelete.Error())))
+			req.span.SetStatus(codes.Error, m)
+		} else {
+			req.span.AddEvent("message.deleted")
+		}
+		req.span.End()
+	}
+
+	return nil
+}
+
+// fail records on each span of batch that deleting failed with err, and ends it.
+func (d *deleter) fail(ctx context.Context, batch []deleteRequest, err error) {
+	d.consumer.RecordDeleted(ctx, d.listener.QueueURL, len(batch), err)
+	batchAttrs := trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(batch)))
+	for _, req := range batch {
+		req.span.AddEvent("message.delete.failed", batchAttrs,
+			trace.WithAttributes(attribute.String("error.message", err.Error())))
+		req.span.SetStatus(codes.Error, "delete failed: "+err.Error())
+		req.span.End()
+	}
+}
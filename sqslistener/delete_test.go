@@ -0,0 +1,341 @@
+package sqslistener
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// partialBatchClient fails the first entry of every DeleteMessageBatch call.
+type partialBatchClient struct {
+	*sqstest.SQS
+	batches atomic.Int32
+	singles atomic.Int32
+}
+
+func (c *partialBatchClient) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	c.batches.Add(1)
+	first := input.Entries[0]
+	rest := *input
+	rest.Entries = input.Entries[1:]
+	out := &sqs.DeleteMessageBatchOutput{}
+	if len(rest.Entries) > 0 {
+		var err error
+		out, err = c.SQS.DeleteMessageBatch(ctx, &rest, optFns...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	out.Failed = append(out.Failed, types.BatchResultErrorEntry{
+		Id:          first.Id,
+		Code:        aws.String("InternalError"),
+		Message:     aws.String("injected failure"),
+		SenderFault: false,
+	})
+	return out, nil
+}
+
+func (c *partialBatchClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.singles.Add(1)
+	return c.SQS.DeleteMessage(ctx, input, optFns...)
+}
+
+func TestListenerDeleteBatch(t *testing.T) {
+	client := &partialBatchClient{SQS: sqstest.New()}
+
+	for range 3 {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("ok")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			return ResultAck
+		}),
+		Workers:         3,
+		DeleteBatchSize: 3,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return len(recorder.Ended()) == 3 })
+
+	cancel()
+	<-done
+
+	if left := client.Messages(testQueueURL); len(left) != 0 {
+		t.Errorf("expected all messages deleted, got: %v", left)
+	}
+	if batches := client.batches.Load(); batches != 1 {
+		t.Errorf("expected 1 batch delete, got %d", batches)
+	}
+	if singles := client.singles.Load(); singles != 1 {
+		t.Errorf("expected 1 individual retry, got %d", singles)
+	}
+
+	// every consumer span records its delete outcome; the failed entry records the retry
+
+	var retried int
+	for _, s := range recorder.Ended() {
+		var events []string
+		for _, e := range s.Events() {
+			events = append(events, e.Name)
+		}
+		switch len(events) {
+		case 1:
+			if events[0] != "message.deleted" {
+				t.Errorf("unexpected events: %v", events)
+			}
+		case 2:
+			retried++
+			if events[0] != "message.delete.failed" || events[1] != "message.deleted" {
+				t.Errorf("unexpected events: %v", events)
+			}
+		default:
+			t.Errorf("unexpected events: %v", events)
+		}
+	}
+	if retried != 1 {
+		t.Errorf("expected 1 retried delete, got %d", retried)
+	}
+}
+
+// throttledDeleteClient fails DeleteMessageBatch calls with a throttling
+// error while failures is positive, and counts delete calls.
+type throttledDeleteClient struct {
+	*sqstest.SQS
+	failures atomic.Int32
+	batches  atomic.Int32
+	singles  atomic.Int32
+}
+
+func (c *throttledDeleteClient) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	c.batches.Add(1)
+	if c.failures.Add(-1) >= 0 {
+		return nil, &smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"}
+	}
+	return c.SQS.DeleteMessageBatch(ctx, input, optFns...)
+}
+
+func (c *throttledDeleteClient) DeleteMessage(ctx context.Context, input *sqs.DeleteMessageInput,
+	optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.singles.Add(1)
+	return c.SQS.DeleteMessage(ctx, input, optFns...)
+}
+
+func TestListenerDeleteBatchRetry(t *testing.T) {
+	client := &throttledDeleteClient{SQS: sqstest.New()}
+	client.failures.Store(2)
+
+	for range 3 {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("ok")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			return ResultAck
+		}),
+		Workers:         3,
+		DeleteBatchSize: 3,
+		Backoff:         Backoff{Initial: 10 * time.Millisecond},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return len(recorder.Ended()) == 3 })
+
+	cancel()
+	<-done
+
+	// the whole batch is retried with DeleteMessageBatch, not message by message
+
+	if left := client.Messages(testQueueURL); len(left) != 0 {
+		t.Errorf("expected all messages deleted, got: %v", left)
+	}
+	if batches := client.batches.Load(); batches != 3 {
+		t.Errorf("expected 3 batch deletes, got %d", batches)
+	}
+	if singles := client.singles.Load(); singles != 0 {
+		t.Errorf("expected no individual deletes, got %d", singles)
+	}
+	for _, s := range recorder.Ended() {
+		if events := s.Events(); len(events) != 1 || events[0].Name != "message.deleted" {
+			t.Errorf("unexpected events: %v", events)
+		}
+	}
+}
+
+func TestListenerDeleteBackoffShutdown(t *testing.T) {
+	client := &throttledDeleteClient{SQS: sqstest.New()}
+	client.failures.Store(1000)
+
+	const total = 6
+
+	for range total {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("ok")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			return ResultAck
+		}),
+		DeleteBatchSize: 1,
+		Backoff:         Backoff{Initial: time.Minute, Jitter: -1},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	// workers keep handling while the deleter backs off
+
+	eventually(t, func() bool { return handled.Load() == total })
+
+	cancel()
+
+	// shutdown does not wait out the backoff
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run waited for the delete backoff")
+	}
+
+	if left := client.Messages(testQueueURL); len(left) != total {
+		t.Errorf("expected messages left for redelivery, got %d", len(left))
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != total {
+		t.Fatalf("expected %d ended spans, got %d", total, len(spans))
+	}
+	for _, s := range spans {
+		if s.Status().Code != codes.Error {
+			t.Errorf("expected failed delete recorded on span, got status: %v", s.Status())
+		}
+	}
+}
+
+func TestListenerDeleteMaxPending(t *testing.T) {
+	client := &throttledDeleteClient{SQS: sqstest.New()}
+	client.failures.Store(1000)
+
+	const (
+		total      = 5
+		maxPending = 2
+	)
+
+	for range total {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("ok")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			return ResultAck
+		}),
+		DeleteBatchSize:  1,
+		DeleteMaxPending: maxPending,
+		Backoff:          Backoff{Initial: time.Minute, Jitter: -1},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	// while the deleter backs off, the oldest deletes are given up
+	// as soon as more than maxPending are waiting
+
+	eventually(t, func() bool { return handled.Load() == total })
+	eventually(t, func() bool { return len(recorder.Ended()) == total-maxPending })
+
+	for _, s := range recorder.Ended() {
+		if s.Status().Code != codes.Error {
+			t.Errorf("expected given up delete recorded on span, got status: %v", s.Status())
+		}
+	}
+
+	cancel()
+	<-done
+
+	if got := len(recorder.Ended()); got != total {
+		t.Errorf("expected %d ended spans after shutdown, got %d", total, got)
+	}
+	if left := client.Messages(testQueueURL); len(left) != total {
+		t.Errorf("expected messages left for redelivery, got %d", len(left))
+	}
+}
@@ -0,0 +1,112 @@
+package sqslistener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// minVisibilityTimeout is the shortest visibility timeout the heartbeat extends:
+// SQS timeouts have one second granularity, and the heartbeat runs every half timeout.
+const minVisibilityTimeout = 2 * time.Second
+
+// visibilityHeartbeat keeps msg invisible in the queue while its handler runs,
+// calling ChangeMessageVisibility every half visibility timeout to extend it
+// by another visibility timeout. The first extension is due half a visibility
+// timeout after received, the time msg was received, so that time spent waiting
+// for a worker is accounted for. Each extension is recorded as an event on span.
+// The heartbeat gives up when the receipt handle is no longer valid, since
+// further extensions would fail the same way, see permanentVisibilityError.
+// The returned function stops the heartbeat; call it before deleting the message.
+// The heartbeat is disabled for visibility timeouts under 2 seconds, see
+// Listener.VisibilityTimeout.
+func (l *Listener) visibilityHeartbeat(ctx context.Context, msg types.Message, received time.Time,
+	span trace.Span) (stop func()) {
+
+	visibilityTimeout := l.VisibilityTimeout
+
+	if visibilityTimeout < minVisibilityTimeout {
+		return func() {}
+	}
+
+	const me = "Listener.visibilityHeartbeat"
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		interval := visibilityTimeout / 2
+
+		timer := time.NewTimer(max(time.Until(received.Add(interval)), 0))
+		defer timer.Stop()
+
+		seconds := int32(visibilityTimeout / time.Second)
+
+		for extension := 1; ; extension++ {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+			}
+
+			timer.Reset(interval)
+
+			input := &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          aws.String(l.QueueURL),
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: seconds,
+			}
+
+			attrs := trace.WithAttributes(
+				attribute.Int("messaging.sqs.visibility.extension", extension),
+				attribute.Int("messaging.sqs.visibility_timeout_s", int(seconds)),
+			)
+
+			if _, errChange := l.Client.ChangeMessageVisibility(ctx, input); errChange != nil {
+				permanent := permanentVisibilityError(errChange)
+				log.Printf("%s: MessageId: %s - sqs.ChangeMessageVisibility: error: %v (giving up: %t)",
+					me, aws.ToString(msg.MessageId), errChange, permanent)
+				span.AddEvent("visibility.extend.failed", attrs,
+					trace.WithAttributes(attribute.String("error.message", errChange.Error())))
+				if permanent {
+					return
+				}
+				continue
+			}
+
+			span.AddEvent("visibility.extended", attrs)
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// permanentVisibilityError reports whether ChangeMessageVisibility failed because
+// the message can no longer be extended: the receipt handle expired, or the
+// message is no longer in flight. Other errors, such as throttling, may clear up.
+func permanentVisibilityError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "ReceiptHandleIsInvalid", "MessageNotInflight", "InvalidParameterValue":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package sqslistener
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// countEvents counts events named name on the ended spans.
+func countEvents(spans []sdktrace.ReadOnlySpan, name string) int {
+	var count int
+	for _, s := range spans {
+		for _, e := range s.Events() {
+			if e.Name == name {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestListenerHeartbeat(t *testing.T) {
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("slow")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			time.Sleep(3 * time.Second) // longer than the visibility timeout
+			return ResultAck
+		}),
+		Workers:           2,
+		VisibilityTimeout: 2 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return len(recorder.Ended()) == 1 })
+
+	cancel()
+	<-done
+
+	// without extension, the other worker would have received the message again
+
+	if got := handled.Load(); got != 1 {
+		t.Errorf("expected message handled once, got %d", got)
+	}
+	if got := countEvents(recorder.Ended(), "visibility.extended"); got < 2 {
+		t.Errorf("expected at least 2 visibility extensions, got %d", got)
+	}
+	if left := client.Messages(testQueueURL); len(left) != 0 {
+		t.Errorf("expected message deleted, got: %v", left)
+	}
+}
+
+func TestHeartbeatFromReceive(t *testing.T) {
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("waited")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+	out, errRecv := client.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
+		QueueUrl: aws.String(testQueueURL), VisibilityTimeout: 2})
+	if errRecv != nil || len(out.Messages) != 1 {
+		t.Fatalf("receive: %v %v", out, errRecv)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	listener := &Listener{
+		Client:            client,
+		QueueURL:          testQueueURL,
+		VisibilityTimeout: 2 * time.Second,
+	}
+
+	// the message waited 900ms for a worker: the first extension is due
+	// 100ms from now, rather than a half timeout from now
+
+	received := time.Now().Add(-900 * time.Millisecond)
+
+	_, span := provider.Tracer("test").Start(context.TODO(), "process")
+	stop := listener.visibilityHeartbeat(context.TODO(), out.Messages[0], received, span)
+	time.Sleep(500 * time.Millisecond)
+	stop()
+	span.End()
+
+	if got := countEvents(recorder.Ended(), "visibility.extended"); got != 1 {
+		t.Errorf("expected 1 visibility extension, got %d", got)
+	}
+}
+
+func TestHeartbeatDisabled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	listener := &Listener{
+		Client:            sqstest.New(),
+		QueueURL:          testQueueURL,
+		VisibilityTimeout: 1500 * time.Millisecond, // under 2s
+	}
+
+	_, span := provider.Tracer("test").Start(context.TODO(), "process")
+	stop := listener.visibilityHeartbeat(context.TODO(), types.Message{}, time.Now().Add(-time.Hour), span)
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	span.End()
+
+	if got := len(recorder.Ended()[0].Events()); got != 0 {
+		t.Errorf("expected no heartbeat events, got %d", got)
+	}
+}
+
+// throttledVisibilityClient fails every ChangeMessageVisibility call with throttling.
+type throttledVisibilityClient struct {
+	*sqstest.SQS
+}
+
+func (c throttledVisibilityClient) ChangeMessageVisibility(_ context.Context, _ *sqs.ChangeMessageVisibilityInput,
+	_ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "RequestThrottled", Message: "slow down"}
+}
+
+func TestHeartbeatFailures(t *testing.T) {
+	table := []struct {
+		name     string
+		client   Client
+		expected int
+	}{
+		// stale receipt handle: give up after the first failure
+		{"invalid receipt handle", sqstest.New(), 1},
+		// throttling may clear up: keep trying
+		{"throttled", throttledVisibilityClient{sqstest.New()}, 2},
+	}
+
+	for _, data := range table {
+		t.Run(data.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			listener := &Listener{
+				Client:            data.client,
+				QueueURL:          testQueueURL,
+				VisibilityTimeout: 2 * time.Second,
+			}
+
+			msg := types.Message{MessageId: aws.String("1"), ReceiptHandle: aws.String("stale")}
+
+			// first extension due right away, the second one a second later
+
+			_, span := provider.Tracer("test").Start(context.TODO(), "process")
+			stop := listener.visibilityHeartbeat(context.TODO(), msg, time.Now().Add(-time.Second), span)
+			time.Sleep(1500 * time.Millisecond)
+			stop()
+			span.End()
+
+			if got := countEvents(recorder.Ended(), "visibility.extend.failed"); got != data.expected {
+				t.Errorf("expected %d failed extensions, got %d", data.expected, got)
+			}
+		})
+	}
+}
+
+func TestListenerNoBufferedExpiry(t *testing.T) {
+	client := sqstest.New()
+
+	const total = 3
+
+	for range total {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("x")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var mutex sync.Mutex
+	handled := map[string]int{}
+
+	// a single worker takes 1.2s per message: received in one batch,
+	// the last message would wait past its 2s visibility timeout
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, msg types.Message) Result {
+			mutex.Lock()
+			handled[aws.ToString(msg.MessageId)]++
+			mutex.Unlock()
+			time.Sleep(1200 * time.Millisecond)
+			return ResultAck
+		}),
+		Workers:           1,
+		VisibilityTimeout: 2 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return len(client.Messages(testQueueURL)) == 0 })
+
+	cancel()
+	<-done
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(handled) != total {
+		t.Errorf("expected %d messages handled, got %d", total, len(handled))
+	}
+	for id, count := range handled {
+		if count != 1 {
+			t.Errorf("MessageId %s: expected handled once, got %d", id, count)
+		}
+	}
+	if got := countEvents(recorder.Ended(), "visibility.extend.failed"); got != 0 {
+		t.Errorf("expected no failed extensions, got %d", got)
+	}
+}
@@ -0,0 +1,401 @@
+/*
+Package sqslistener implements a traced SQS consumer.
+
+Listener long-polls a queue, extracts the trace context from each message,
+hands the message to a Handler under a consumer span, and settles the message
+according to the Result: deleting it, leaving it for redelivery or NACKing it.
+
+# Usage
+
+	listener := &sqslistener.Listener{
+	    Client:   sqs.NewFromConfig(cfg),
+	    QueueURL: queueURL,
+	    Handler: sqslistener.HandlerFunc(func(ctx context.Context, msg types.Message) sqslistener.Result {
+	        if err := process(ctx, msg); err != nil {
+	            return sqslistener.ResultRetry
+	        }
+	        return sqslistener.ResultAck
+	    }),
+	    Workers: 4,
+	}
+
+	listener.Run(ctx) // returns when ctx is cancelled
+*/
+package sqslistener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Handler handles messages received by Listener.
+type Handler interface {
+	// Handle processes msg, returning how it must be settled.
+	// ctx holds the consumer span for msg.
+	Handle(ctx context.Context, msg types.Message) Result
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(ctx context.Context, msg types.Message) Result
+
+// Handle calls f(ctx, msg).
+func (f HandlerFunc) Handle(ctx context.Context, msg types.Message) Result {
+	return f(ctx, msg)
+}
+
+// Client is the subset of the SQS API used by Listener.
+// It is satisfied by *sqs.Client, and by in-memory fakes in tests.
+type Client interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput,
+		optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput,
+		optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput,
+		optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+var _ Client = (*sqs.Client)(nil)
+
+// Listener receives messages from an SQS queue and hands them to Handler.
+type Listener struct {
+	// Client is the SQS client.
+	Client Client
+
+	// QueueURL is the queue to receive messages from.
+	QueueURL string
+
+	// Handler handles received messages.
+	Handler Handler
+
+	// Carrier extracts trace context and starts consumer spans.
+	// Defaults to otelsqs.NewCarrier().
+	Carrier *otelsqs.SqsCarrierAttributes
+
+	// Receivers is the number of goroutines receiving messages. Defaults to 1.
+	Receivers int
+
+	// Workers is the number of goroutines handling messages. Defaults to 1.
+	// Receivers request no more messages than there are idle workers,
+	// so that received messages do not wait for a worker; when all workers
+	// are busy, receivers pause.
+	Workers int
+
+	// VisibilityTimeout is requested when receiving messages, and periodically
+	// extended while a message is handled. It is truncated to whole seconds.
+	// Zero disables the extension, leaving the queue default visibility timeout.
+	// Values under 2 seconds are too short to extend, hence disable it as well.
+	VisibilityTimeout time.Duration
+
+	// NackDelay is the visibility delay for messages handled with ResultNack.
+	NackDelay time.Duration
+
+	// DeleteBatchSize is the maximum number of acknowledged messages deleted
+	// with a single DeleteMessageBatch call. Defaults to 10, the SQS maximum.
+	DeleteBatchSize int
+
+	// DeleteBatchInterval is the maximum time an acknowledged message waits
+	// for its batch to fill before the batch is deleted. Defaults to 200ms.
+	DeleteBatchInterval time.Duration
+
+	// DeleteMaxPending is the maximum number of acknowledged messages waiting
+	// for deletion, for instance while DeleteMessageBatch is throttled. Beyond
+	// it, the oldest are given up and left in the queue for redelivery.
+	// Defaults to 1000; values under DeleteBatchSize are raised to it.
+	DeleteMaxPending int
+
+	// Backoff is the delay policy for retrying failed receive and delete calls.
+	// Consecutive failures grow the delay, a success resets it.
+	Backoff Backoff
+
+	// Debug enables verbose logs.
+	Debug bool
+}
+
+// Run runs the listener until ctx is cancelled.
+// Receivers goroutines receive messages for Workers goroutines, each receive
+// requesting at most as many messages as there are idle workers; when all
+// workers are busy, receivers pause.
+// On cancellation, it stops receiving, finishes messages in flight and returns;
+// messages received but not yet picked by a worker are left in the queue for redelivery.
+// Messages in flight are handled under a context detached from ctx cancellation,
+// so the caller should bound the wait for Run to return.
+// Acknowledged messages are deleted in batches, see DeleteBatchSize and
+// DeleteBatchInterval; pending deletes are flushed before Run returns.
+func (l *Listener) Run(ctx context.Context) {
+
+	const me = "Listener.Run"
+
+	consumer := l.Carrier
+	if consumer == nil {
+		consumer = otelsqs.NewCarrier()
+	}
+
+	receivers := max(l.Receivers, 1)
+	workers := max(l.Workers, 1)
+
+	if l.VisibilityTimeout > 0 && l.VisibilityTimeout < minVisibilityTimeout {
+		log.Printf("%s: VisibilityTimeout=%v under %v: visibility extension disabled",
+			me, l.VisibilityTimeout, minVisibilityTimeout)
+	}
+
+	// idle holds one token per idle worker: receivers take tokens before
+	// receiving, so that every received message has a worker waiting for it,
+	// and its visibility timeout does not run out while it sits in the channel.
+	idle := make(chan struct{}, workers)
+	for range workers {
+		idle <- struct{}{}
+	}
+
+	messages := make(chan receivedMessage, workers)
+
+	var receiversGroup, workersGroup sync.WaitGroup
+
+	for i := range receivers {
+		receiversGroup.Add(1)
+		go func() {
+			defer receiversGroup.Done()
+			l.receive(ctx, i, consumer, idle, messages)
+		}()
+	}
+
+	// in-flight messages are not interrupted by cancellation
+	ctxHandle := context.WithoutCancel(ctx)
+
+	deletes := l.newDeleter(consumer)
+	deleterDone := make(chan struct{})
+	go func() {
+		deletes.run(ctxHandle)
+		close(deleterDone)
+	}()
+
+	for range workers {
+		workersGroup.Add(1)
+		go func() {
+			defer workersGroup.Done()
+			for m := range messages {
+				if ctx.Err() != nil {
+					log.Printf("%s: stopping: leaving MessageId: %s for redelivery",
+						me, aws.ToString(m.msg.MessageId))
+				} else {
+					l.process(ctxHandle, consumer, m, deletes)
+				}
+				idle <- struct{}{}
+			}
+		}()
+	}
+
+	receiversGroup.Wait()
+	close(messages)
+	workersGroup.Wait()
+	deletes.close()
+	<-deleterDone
+
+	log.Printf("%s: stopped: %s: %v", me, l.QueueURL, context.Cause(ctx))
+}
+
+// receivedMessage is a message waiting for a worker,
+// with the time it was received.
+type receivedMessage struct {
+	msg      types.Message
+	received time.Time
+}
+
+// receive receives messages from the queue into channel messages,
+// until ctx is cancelled.
+// Each receive requests one message per token taken from idle, waiting for
+// at least one; tokens left unused by the receive are returned to idle.
+// Failed receives are retried after a backoff, see Listener.Backoff.
+func (l *Listener) receive(ctx context.Context, receiver int, consumer *otelsqs.SqsCarrierAttributes,
+	idle chan struct{}, messages chan<- receivedMessage) {
+
+	const me = "Listener.receive"
+
+	debug := l.Debug
+
+	const waitTime = 20 * time.Second // 0..20s
+
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl: aws.String(l.QueueURL),
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			"SentTimestamp",
+			"AWSTraceHeader",
+		},
+		MessageAttributeNames: []string{
+			"All",
+		},
+		WaitTimeSeconds: int32(waitTime / time.Second),
+	}
+
+	if l.VisibilityTimeout >= minVisibilityTimeout {
+		input.VisibilityTimeout = int32(l.VisibilityTimeout / time.Second)
+	}
+
+	retry := retrier{policy: l.Backoff}
+
+	for ctx.Err() == nil {
+		if debug {
+			log.Printf("%s: %d: ready: %s", me, receiver, l.QueueURL)
+		}
+
+		//
+		// wait for idle workers
+		//
+
+		tokens := takeTokens(ctx, idle, maxReceiveMessages)
+		if tokens == 0 {
+			break
+		}
+
+		//
+		// read message from sqs queue
+		//
+
+		input.MaxNumberOfMessages = int32(tokens)
+
+		begin := time.Now()
+
+		resp, errRecv := l.Client.ReceiveMessage(ctx, input)
+		received := time.Now()
+
+		var count int
+		if errRecv == nil {
+			count = len(resp.Messages)
+		}
+		returnTokens(idle, tokens-count)
+
+		if errRecv != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			delay := retry.next()
+			log.Printf("%s: %d: sqs.ReceiveMessage: error: %v, sleeping %v",
+				me, receiver, errRecv, delay)
+			consumer.RecordBackoff(ctx, l.QueueURL, "receive", delay, errRecv)
+			sleep(ctx, delay)
+			continue
+		}
+
+		//
+		// push messages into channel
+		//
+
+		if debug {
+			log.Printf("%s: %d: sqs.ReceiveMessage: found %d messages", me, receiver, count)
+		}
+
+		if count == 0 {
+			// long polling already waited on the server, so real SQS needs
+			// no sleep. the backoff prevents us from hammering simulated apis
+			// that return empty receives right away.
+			if time.Since(begin) >= waitTime/2 {
+				retry.reset()
+				continue
+			}
+			delay := retry.next()
+			if debug {
+				log.Printf("%s: %d: empty receive without long polling, sleeping %v",
+					me, receiver, delay)
+			}
+			consumer.RecordBackoff(ctx, l.QueueURL, "receive", delay, nil)
+			sleep(ctx, delay)
+			continue
+		}
+
+		retry.reset()
+
+		for i, msg := range resp.Messages {
+			if debug {
+				log.Printf("%s: %d: %d/%d MessageId: %s", me, receiver, i+1, count, aws.ToString(msg.MessageId))
+			}
+			select {
+			case messages <- receivedMessage{msg: msg, received: received}:
+			case <-ctx.Done():
+				log.Printf("%s: %d: stopping: leaving %d/%d messages for redelivery",
+					me, receiver, count-i, count)
+				returnTokens(idle, count-i)
+				return
+			}
+		}
+	}
+}
+
+// maxReceiveMessages is the most messages a single ReceiveMessage call returns.
+const maxReceiveMessages = 10
+
+// takeTokens waits for a token from idle, then takes whatever other tokens
+// are available, up to limit. It returns the number of tokens taken, or zero
+// if ctx was cancelled first.
+func takeTokens(ctx context.Context, idle <-chan struct{}, limit int) int {
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return 0
+	}
+	tokens := 1
+	for tokens < limit {
+		select {
+		case <-idle:
+			tokens++
+		default:
+			return tokens
+		}
+	}
+	return tokens
+}
+
+// returnTokens gives count tokens back to idle.
+func returnTokens(idle chan<- struct{}, count int) {
+	for range count {
+		idle <- struct{}{}
+	}
+}
+
+// process hands a message to the handler, then settles it: it is deleted from
+// the queue only when handled successfully, see Result.
+// While the message is handled, its visibility timeout is periodically extended.
+func (l *Listener) process(ctx context.Context, consumer *otelsqs.SqsCarrierAttributes, m receivedMessage,
+	deletes *deleter) {
+
+	msg := m.msg
+
+	ctx, span := consumer.StartConsumerSpanAt(ctx, l.QueueURL, msg, m.received)
+
+	stopHeartbeat := l.visibilityHeartbeat(ctx, msg, m.received, span)
+
+	begin := time.Now()
+
+	result := l.Handler.Handle(ctx, msg)
+
+	stopHeartbeat()
+
+	var errHandle error
+	if result != ResultAck {
+		errHandle = fmt.Errorf("handling failed: %s", result)
+		span.SetStatus(codes.Error, "handling failed")
+	}
+
+	consumer.RecordProcessed(ctx, l.QueueURL, time.Since(begin), errHandle)
+
+	l.settle(ctx, msg, result, span, deletes)
+}
+
+// sleep pauses for duration d, or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
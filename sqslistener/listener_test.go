@@ -0,0 +1,449 @@
+package sqslistener
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	var got string
+
+	var h Handler = HandlerFunc(func(_ context.Context, msg types.Message) Result {
+		got = aws.ToString(msg.Body)
+		return ResultNack
+	})
+
+	result := h.Handle(context.TODO(), types.Message{Body: aws.String("hello")})
+
+	if got != "hello" {
+		t.Errorf("handler received unexpected body: %q", got)
+	}
+	if result != ResultNack {
+		t.Errorf("expected ResultNack, got %v", result)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	table := map[Result]string{
+		ResultAck:   "ack",
+		ResultRetry: "retry",
+		ResultNack:  "nack",
+		Result(9):   "Result(9)",
+	}
+	for result, expected := range table {
+		if result.String() != expected {
+			t.Errorf("expected %q, got %q", expected, result.String())
+		}
+	}
+}
+
+const testQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/input"
+
+// eventually polls cond until it holds or the deadline expires.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListenerRun(t *testing.T) {
+	client := sqstest.New()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// producer
+
+	producer := otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider))
+
+	for _, body := range []string{"ok", "fail", "ok"} {
+		input := &sqs.SendMessageInput{QueueUrl: aws.String(testQueueURL), MessageBody: aws.String(body)}
+		_, span, errInject := producer.StartProducerSpan(context.TODO(), input)
+		if errInject != nil {
+			t.Fatalf("inject: %v", errInject)
+		}
+		if _, errSend := client.SendMessage(context.TODO(), input); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+		span.End()
+	}
+
+	// consumer
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier:  otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider)),
+		Handler: HandlerFunc(func(_ context.Context, msg types.Message) Result {
+			defer handled.Add(1)
+			if aws.ToString(msg.Body) == "ok" {
+				return ResultAck
+			}
+			return ResultRetry
+		}),
+		Workers: 2,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return handled.Load() == 3 && len(recorder.Ended()) == 6 })
+
+	cancel()
+	<-done
+
+	// only the failed message is left in the queue
+
+	left := client.Messages(testQueueURL)
+	if len(left) != 1 || aws.ToString(left[0].Body) != "fail" {
+		t.Errorf("expected only failed message left, got: %v", left)
+	}
+
+	// each consumer span continues its producer span and records the settlement
+
+	producers := map[trace.SpanID]bool{}
+	var consumers []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		switch s.SpanKind() {
+		case trace.SpanKindProducer:
+			producers[s.SpanContext().SpanID()] = true
+		case trace.SpanKindConsumer:
+			consumers = append(consumers, s)
+		}
+	}
+
+	if len(consumers) != 3 {
+		t.Fatalf("expected 3 consumer spans, got %d", len(consumers))
+	}
+
+	settlements := map[string]int{}
+	for _, s := range consumers {
+		if !producers[s.Parent().SpanID()] {
+			t.Errorf("consumer span not parented to a producer span: %v", s.Parent())
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == SettlementAttribute {
+				settlements[kv.Value.AsString()]++
+			}
+		}
+	}
+	if settlements["ack"] != 2 || settlements["retry"] != 1 {
+		t.Errorf("unexpected settlements: %v", settlements)
+	}
+}
+
+func TestListenerNack(t *testing.T) {
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("fail")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			return ResultNack
+		}),
+		NackDelay: 0, // redeliver immediately
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	// NACKed message is redelivered right away, instead of after the visibility timeout
+
+	eventually(t, func() bool { return handled.Load() >= 2 })
+
+	cancel()
+	<-done
+
+	left := client.Messages(testQueueURL)
+	if len(left) != 1 {
+		t.Fatalf("expected message left in queue, got: %v", left)
+	}
+	if count := left[0].Attributes["ApproximateReceiveCount"]; count == "1" {
+		t.Errorf("expected message redelivered, receive count: %s", count)
+	}
+}
+
+func TestListenerCancelInFlight(t *testing.T) {
+	client := sqstest.New()
+
+	for _, body := range []string{"a", "b", "c"} {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String(body)}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+
+	var handled, cancelled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Handler: HandlerFunc(func(ctx context.Context, _ types.Message) Result {
+			started <- struct{}{}
+			<-release
+			if ctx.Err() != nil {
+				cancelled.Add(1)
+			}
+			handled.Add(1)
+			return ResultAck
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+
+	cancel()
+
+	// Run waits for the message in flight
+
+	select {
+	case <-done:
+		t.Fatalf("Run returned with a message in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+
+	if got := handled.Load(); got != 1 {
+		t.Errorf("expected only the message in flight handled, got %d", got)
+	}
+	if cancelled.Load() != 0 {
+		t.Errorf("handler context cancelled for message in flight")
+	}
+
+	// the message in flight is deleted, the others are left for redelivery
+
+	left := client.Messages(testQueueURL)
+	if len(left) != 2 {
+		t.Errorf("expected 2 messages left in queue, got: %v", left)
+	}
+}
+
+func TestListenerBoundedPool(t *testing.T) {
+	client := sqstest.New()
+
+	const total = 30
+
+	for range total {
+		if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+			QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("x")}); errSend != nil {
+			t.Fatalf("send: %v", errSend)
+		}
+	}
+
+	const workers = 2
+
+	release := make(chan struct{})
+
+	var active, peak, handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			active.Add(-1)
+			handled.Add(1)
+			return ResultAck
+		}),
+		Workers: workers,
+	}
+
+	received := func() int {
+		var count int
+		for _, msg := range client.Messages(testQueueURL) {
+			if _, found := msg.Attributes["ApproximateReceiveCount"]; found {
+				count++
+			}
+		}
+		return count
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return active.Load() == workers })
+
+	// give the receiver time to receive more, then it must pause:
+	// it receives only for idle workers, and both workers are busy
+
+	time.Sleep(200 * time.Millisecond)
+
+	if got, limit := received(), workers; got > limit {
+		t.Errorf("receiver did not pause: received %d messages, limit %d", got, limit)
+	}
+
+	close(release)
+
+	eventually(t, func() bool { return handled.Load() == total })
+
+	cancel()
+	<-done
+
+	if got := peak.Load(); got > workers {
+		t.Errorf("expected at most %d concurrent handlers, got %d", workers, got)
+	}
+	if left := client.Messages(testQueueURL); len(left) != 0 {
+		t.Errorf("expected queue drained, got %d messages", len(left))
+	}
+}
+
+func TestListenerRetry(t *testing.T) {
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("fail")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	var handled atomic.Int32
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			handled.Add(1)
+			return ResultRetry
+		}),
+		Workers: 2,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool { return handled.Load() == 1 })
+
+	// the message stays invisible until its visibility timeout expires
+
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-done
+
+	if got := handled.Load(); got != 1 {
+		t.Errorf("expected no redelivery before the visibility timeout, handled %d times", got)
+	}
+
+	left := client.Messages(testQueueURL)
+	if len(left) != 1 {
+		t.Fatalf("expected message left in queue, got: %v", left)
+	}
+	if count := left[0].Attributes["ApproximateReceiveCount"]; count != "1" {
+		t.Errorf("expected message received once, receive count: %s", count)
+	}
+}
+
+func TestListenerNackDelay(t *testing.T) {
+	client := sqstest.New()
+
+	if _, errSend := client.SendMessage(context.TODO(), &sqs.SendMessageInput{
+		QueueUrl: aws.String(testQueueURL), MessageBody: aws.String("fail")}); errSend != nil {
+		t.Fatalf("send: %v", errSend)
+	}
+
+	const nackDelay = time.Second
+
+	var mutex sync.Mutex
+	var handledAt []time.Time
+
+	listener := &Listener{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Handler: HandlerFunc(func(_ context.Context, _ types.Message) Result {
+			mutex.Lock()
+			handledAt = append(handledAt, time.Now())
+			mutex.Unlock()
+			return ResultNack
+		}),
+		NackDelay: nackDelay,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+
+	eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(handledAt) >= 2
+	})
+
+	cancel()
+	<-done
+
+	// redelivered after the NACK delay, well before the default visibility timeout
+
+	gap := handledAt[1].Sub(handledAt[0])
+	if gap < nackDelay-100*time.Millisecond || gap > sqstest.DefaultVisibilityTimeout/2 {
+		t.Errorf("expected redelivery after %v, got %v", nackDelay, gap)
+	}
+}
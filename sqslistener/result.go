@@ -0,0 +1,83 @@
+package sqslistener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Result is the outcome of handling a message, deciding its settlement.
+type Result int
+
+const (
+	// ResultAck deletes the message from the queue.
+	ResultAck Result = iota
+
+	// ResultRetry leaves the message in the queue, to be redelivered
+	// when its visibility timeout expires.
+	ResultRetry
+
+	// ResultNack makes the message visible again after Listener.NackDelay.
+	ResultNack
+)
+
+// String returns the result name.
+func (r Result) String() string {
+	switch r {
+	case ResultAck:
+		return "ack"
+	case ResultRetry:
+		return "retry"
+	case ResultNack:
+		return "nack"
+	}
+	return fmt.Sprintf("Result(%d)", int(r))
+}
+
+// SettlementAttribute is the span attribute recording the message settlement.
+const SettlementAttribute = "messaging.sqs.settlement"
+
+// settle deletes, leaves or NACKs the message according to result,
+// recording the decision on span, and ends span.
+// Acknowledged messages are queued for batch deletion, and their span ends
+// once the delete outcome is recorded.
+func (l *Listener) settle(ctx context.Context, msg types.Message, result Result, span trace.Span,
+	deletes *deleter) {
+
+	const me = "Listener.settle"
+
+	span.SetAttributes(attribute.String(SettlementAttribute, result.String()))
+
+	switch result {
+	case ResultAck:
+		deletes.add(ctx, msg, span)
+		return
+
+	case ResultNack:
+		delay := max(l.NackDelay, 0)
+		inputChange := &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(l.QueueURL),
+			ReceiptHandle:     msg.ReceiptHandle,
+			VisibilityTimeout: int32(delay / time.Second),
+		}
+		span.AddEvent("nack", trace.WithAttributes(
+			attribute.Int("messaging.sqs.visibility_timeout_s", int(delay/time.Second))))
+		if _, errChange := l.Client.ChangeMessageVisibility(ctx, inputChange); errChange != nil {
+			log.Printf("%s: MessageId: %s - sqs.ChangeMessageVisibility: error: %v",
+				me, aws.ToString(msg.MessageId), errChange)
+		}
+
+	case ResultRetry:
+		log.Printf("%s: MessageId: %s - left for redelivery",
+			me, aws.ToString(msg.MessageId))
+	}
+
+	span.End()
+}
@@ -0,0 +1,329 @@
+/*
+Package sqssender implements a traced SQS producer batching messages with SendMessageBatch.
+
+Sender buffers messages into SendMessageBatch calls of up to 10 entries or
+256 KiB, flushing when a batch is full or after the linger time. Each message
+still gets its own producer span and its own injected trace context, and Send
+reports the outcome of that message alone.
+
+# Usage
+
+	sender := &sqssender.Sender{
+	    Client:   sqs.NewFromConfig(cfg),
+	    QueueURL: queueURL,
+	}
+
+	go sender.Run(ctx) // returns when ctx is cancelled, after flushing pending messages
+
+	messageID, err := sender.Send(ctx, &sqs.SendMessageInput{MessageBody: aws.String(body)})
+*/
+package sqssender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+)
+
+// SQS limits for SendMessageBatch, used as defaults.
+const (
+	DefaultMaxEntries = 10
+	DefaultMaxBytes   = 256 * 1024
+	DefaultLinger     = 20 * time.Millisecond
+)
+
+// ErrStopped is returned by Send after Run has returned.
+var ErrStopped = errors.New("sqssender: sender stopped")
+
+// ErrEntryFailed is returned by Send when SendMessageBatch reports the
+// message entry as failed. The error message holds the SQS error code.
+var ErrEntryFailed = errors.New("sqssender: batch entry failed")
+
+// Client is the subset of the SQS API used by Sender.
+// It is satisfied by *sqs.Client, and by in-memory fakes in tests.
+type Client interface {
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput,
+		optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+var _ Client = (*sqs.Client)(nil)
+
+// Sender sends messages to an SQS queue in batches.
+// Send may be called from concurrent goroutines while Run runs.
+type Sender struct {
+	// Client is the SQS client.
+	Client Client
+
+	// QueueURL is the queue to send messages to.
+	QueueURL string
+
+	// Carrier injects trace context and starts producer spans.
+	// Defaults to otelsqs.NewCarrier().
+	Carrier *otelsqs.SqsCarrierAttributes
+
+	// MaxEntries is the maximum number of messages per batch. Defaults to 10, the SQS maximum.
+	MaxEntries int
+
+	// MaxBytes is the maximum batch payload, body plus message attributes.
+	// Defaults to 256 KiB, the SQS maximum.
+	MaxBytes int
+
+	// Linger is the maximum time a message waits for its batch to fill. Defaults to 20ms.
+	Linger time.Duration
+
+	// Debug enables verbose logs.
+	Debug bool
+
+	once     sync.Once
+	requests chan *request
+	stopped  chan struct{}
+}
+
+// request is a message waiting for its batch.
+type request struct {
+	entry  types.SendMessageBatchRequestEntry
+	size   int
+	result chan result
+}
+
+type result struct {
+	messageID string
+	batchSize int
+	err       error
+}
+
+func (s *Sender) init() {
+	s.once.Do(func() {
+		s.requests = make(chan *request)
+		s.stopped = make(chan struct{})
+	})
+}
+
+func (s *Sender) carrier() *otelsqs.SqsCarrierAttributes {
+	if s.Carrier == nil {
+		return otelsqs.NewCarrier()
+	}
+	return s.Carrier
+}
+
+// Send queues the message for the next batch and waits for its outcome,
+// returning the message id assigned by SQS.
+// It starts a producer span and injects the trace context into
+// input.MessageAttributes, like otelsqs.StartProducerSpan.
+// input.QueueUrl is set to QueueURL. Fields with no batch entry counterpart are ignored.
+// Send blocks until Run flushes the batch holding the message.
+// ctx only bounds the wait for Run to accept the message: once accepted, the
+// message is sent even if ctx is done, and Send waits for the outcome, at most
+// Linger plus one SendMessageBatch call, rather than returning ctx.Err() for
+// a message that may still be sent.
+func (s *Sender) Send(ctx context.Context, input *sqs.SendMessageInput) (string, error) {
+
+	const me = "Sender.Send"
+
+	s.init()
+
+	input.QueueUrl = aws.String(s.QueueURL)
+
+	ctx, span, errInject := s.carrier().StartProducerSpan(ctx, input)
+	defer span.End()
+
+	if errInject != nil {
+		// the message is still sent, untraced downstream
+		log.Printf("%s: inject: %v", me, errInject)
+	}
+
+	req := &request{
+		entry: types.SendMessageBatchRequestEntry{
+			MessageBody:             input.MessageBody,
+			DelaySeconds:            input.DelaySeconds,
+			MessageAttributes:       input.MessageAttributes,
+			MessageSystemAttributes: input.MessageSystemAttributes,
+			MessageDeduplicationId:  input.MessageDeduplicationId,
+			MessageGroupId:          input.MessageGroupId,
+		},
+		size:   messageSize(input),
+		result: make(chan result, 1),
+	}
+
+	var res result
+
+	select {
+	case s.requests <- req:
+		// Run answers every accepted request, even after cancellation
+		res = <-req.result
+	case <-s.stopped:
+		res.err = ErrStopped
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+
+	if res.batchSize > 0 {
+		span.SetAttributes(semconv.MessagingBatchMessageCount(res.batchSize))
+	}
+
+	if res.err != nil {
+		span.RecordError(res.err)
+		span.SetStatus(codes.Error, res.err.Error())
+		return "", res.err
+	}
+
+	span.SetAttributes(semconv.MessagingMessageID(res.messageID))
+
+	return res.messageID, nil
+}
+
+// messageSize computes the payload size as accounted by SQS:
+// body plus names, data types and values of message attributes.
+func messageSize(input *sqs.SendMessageInput) int {
+	size := len(aws.ToString(input.MessageBody))
+	for name, value := range input.MessageAttributes {
+		size += len(name) + len(aws.ToString(value.DataType)) +
+			len(aws.ToString(value.StringValue)) + len(value.BinaryValue)
+	}
+	return size
+}
+
+// Run collects messages from Send into batches until ctx is cancelled.
+// A batch is sent when adding a message would exceed MaxEntries or MaxBytes,
+// or when its oldest message waited for Linger.
+// On cancellation, it sends the pending batch and returns; later calls to Send
+// return ErrStopped.
+func (s *Sender) Run(ctx context.Context) {
+
+	const me = "Sender.Run"
+
+	s.init()
+	defer close(s.stopped)
+
+	maxEntries := s.MaxEntries
+	if maxEntries < 1 || maxEntries > DefaultMaxEntries {
+		maxEntries = DefaultMaxEntries
+	}
+	maxBytes := s.MaxBytes
+	if maxBytes < 1 || maxBytes > DefaultMaxBytes {
+		maxBytes = DefaultMaxBytes
+	}
+	linger := s.Linger
+	if linger <= 0 {
+		linger = DefaultLinger
+	}
+
+	// pending messages are sent even after cancellation
+	ctxSend := context.WithoutCancel(ctx)
+
+	timer := time.NewTimer(linger)
+	timer.Stop()
+	defer timer.Stop()
+
+	var batch []*request
+	var size int
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			s.flush(ctxSend, batch)
+			batch, size = nil, 0
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			log.Printf("%s: stopped: %s: %v", me, s.QueueURL, context.Cause(ctx))
+			return
+		case req := <-s.requests:
+			if len(batch) > 0 && size+req.size > maxBytes {
+				flush()
+			}
+			batch = append(batch, req)
+			size += req.size
+			if len(batch) == 1 {
+				timer.Reset(linger)
+			}
+			if len(batch) == maxEntries {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// flush sends batch with SendMessageBatch and reports each entry outcome to its sender.
+func (s *Sender) flush(ctx context.Context, batch []*request) {
+
+	const me = "Sender.flush"
+
+	input := &sqs.SendMessageBatchInput{QueueUrl: aws.String(s.QueueURL)}
+	for i, req := range batch {
+		entry := req.entry
+		entry.Id = aws.String(strconv.Itoa(i))
+		input.Entries = append(input.Entries, entry)
+	}
+
+	carrier := s.carrier()
+
+	out, errBatch := s.Client.SendMessageBatch(ctx, input)
+	if errBatch != nil {
+		log.Printf("%s: sqs.SendMessageBatch: %d messages: error: %v", me, len(batch), errBatch)
+		carrier.RecordSent(ctx, s.QueueURL, len(batch), errBatch)
+		for _, req := range batch {
+			req.result <- result{batchSize: len(batch), err: errBatch}
+		}
+		return
+	}
+
+	if s.Debug {
+		log.Printf("%s: sqs.SendMessageBatch: %d messages: successful=%d failed=%d",
+			me, len(batch), len(out.Successful), len(out.Failed))
+	}
+
+	results := make([]result, len(batch))
+	answered := make([]bool, len(batch))
+
+	record := func(id *string, res result) {
+		i, errID := strconv.Atoi(aws.ToString(id))
+		if errID != nil || i < 0 || i >= len(batch) || answered[i] {
+			log.Printf("%s: sqs.SendMessageBatch: unexpected entry id: %s", me, aws.ToString(id))
+			return
+		}
+		results[i], answered[i] = res, true
+	}
+
+	for _, entry := range out.Successful {
+		record(entry.Id, result{messageID: aws.ToString(entry.MessageId)})
+	}
+	for _, entry := range out.Failed {
+		record(entry.Id, result{err: fmt.Errorf("%w: %s: %s",
+			ErrEntryFailed, aws.ToString(entry.Code), aws.ToString(entry.Message))})
+	}
+
+	var failed int
+	for i, req := range batch {
+		if !answered[i] {
+			results[i].err = fmt.Errorf("%w: missing from response", ErrEntryFailed)
+		}
+		if results[i].err != nil {
+			failed++
+		}
+		results[i].batchSize = len(batch)
+		req.result <- results[i]
+	}
+
+	carrier.RecordSent(ctx, s.QueueURL, len(batch)-failed, nil)
+	if failed > 0 {
+		carrier.RecordSent(ctx, s.QueueURL, failed, ErrEntryFailed)
+	}
+}
@@ -0,0 +1,212 @@
+package sqssender
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs/sqstest"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/output"
+
+// countingClient counts SendMessageBatch calls.
+type countingClient struct {
+	*sqstest.SQS
+	batches atomic.Int32
+}
+
+func (c *countingClient) SendMessageBatch(ctx context.Context, input *sqs.SendMessageBatchInput,
+	optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	c.batches.Add(1)
+	return c.SQS.SendMessageBatch(ctx, input, optFns...)
+}
+
+// startSender runs sender until the test ends.
+func startSender(t *testing.T, sender *Sender) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sender.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// sendAll sends bodies from concurrent goroutines, returning errors by body.
+func sendAll(sender *Sender, bodies []string) map[string]error {
+	var mutex sync.Mutex
+	errs := map[string]error{}
+	var wg sync.WaitGroup
+	for _, body := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := sender.Send(context.TODO(), &sqs.SendMessageInput{MessageBody: aws.String(body)})
+			mutex.Lock()
+			errs[body] = err
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestSenderBatch(t *testing.T) {
+	client := &countingClient{SQS: sqstest.New()}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	sender := &Sender{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Carrier: otelsqs.NewCarrier(otelsqs.WithTracerProvider(provider),
+			otelsqs.WithPropagator(propagation.TraceContext{})),
+		Linger: time.Second,
+	}
+	startSender(t, sender)
+
+	bodies := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+	for body, err := range sendAll(sender, bodies) {
+		if err != nil {
+			t.Errorf("send %s: %v", body, err)
+		}
+	}
+
+	// a full batch is flushed without waiting for the linger time
+
+	if batches := client.batches.Load(); batches != 1 {
+		t.Errorf("expected 1 batch, got %d", batches)
+	}
+
+	// each message carries the trace context of its own producer span
+
+	spans := map[trace.SpanID]bool{}
+	for _, s := range recorder.Ended() {
+		if s.SpanKind() == trace.SpanKindProducer {
+			spans[s.SpanContext().SpanID()] = true
+		}
+	}
+	if len(spans) != len(bodies) {
+		t.Fatalf("expected %d producer spans, got %d", len(bodies), len(spans))
+	}
+
+	consumer := otelsqs.NewCarrier(otelsqs.WithPropagator(propagation.TraceContext{}))
+	for _, msg := range client.Messages(testQueueURL) {
+		ctx := consumer.ExtractMessage(context.TODO(), msg)
+		spanID := trace.SpanContextFromContext(ctx).SpanID()
+		if !spans[spanID] {
+			t.Errorf("message %s not parented to a producer span: %s", aws.ToString(msg.Body), spanID)
+		}
+		delete(spans, spanID)
+	}
+	if len(spans) != 0 {
+		t.Errorf("producer spans without message: %d", len(spans))
+	}
+}
+
+func TestSenderPartialFailure(t *testing.T) {
+	client := &countingClient{SQS: sqstest.New()}
+
+	sender := &Sender{
+		Client:     client,
+		QueueURL:   testQueueURL,
+		MaxEntries: 3,
+		Linger:     time.Second,
+	}
+	startSender(t, sender)
+
+	// SQS refuses the empty body
+
+	errs := sendAll(sender, []string{"a", "", "c"})
+
+	if errs["a"] != nil || errs["c"] != nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if !errors.Is(errs[""], ErrEntryFailed) {
+		t.Errorf("expected ErrEntryFailed, got %v", errs[""])
+	}
+	if left := client.Messages(testQueueURL); len(left) != 2 {
+		t.Errorf("expected 2 messages sent, got %d", len(left))
+	}
+}
+
+func TestSenderMaxBytes(t *testing.T) {
+	client := &countingClient{SQS: sqstest.New()}
+
+	sender := &Sender{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Linger:   10 * time.Millisecond,
+	}
+	startSender(t, sender)
+
+	// two messages over half the limit cannot share a batch
+
+	big := strings.Repeat("x", DefaultMaxBytes/2+1)
+	for body, err := range sendAll(sender, []string{big + "1", big + "2"}) {
+		if err != nil {
+			t.Errorf("send %d bytes: %v", len(body), err)
+		}
+	}
+
+	if batches := client.batches.Load(); batches != 2 {
+		t.Errorf("expected 2 batches, got %d", batches)
+	}
+}
+
+func TestSenderStopped(t *testing.T) {
+	sender := &Sender{Client: sqstest.New(), QueueURL: testQueueURL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sender.Run(ctx)
+
+	_, err := sender.Send(context.TODO(), &sqs.SendMessageInput{MessageBody: aws.String("late")})
+	if !errors.Is(err, ErrStopped) {
+		t.Errorf("expected ErrStopped, got %v", err)
+	}
+}
+
+func TestSenderCancelAfterQueued(t *testing.T) {
+	client := sqstest.New()
+
+	sender := &Sender{Client: client, QueueURL: testQueueURL, Linger: 200 * time.Millisecond}
+	startSender(t, sender)
+
+	// ctx expires while the message waits for its batch
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	id, err := sender.Send(ctx, &sqs.SendMessageInput{MessageBody: aws.String("queued")})
+	if err != nil {
+		t.Fatalf("expected the queued message sent despite cancellation, got: %v", err)
+	}
+	if id == "" {
+		t.Errorf("missing message id")
+	}
+	if ctx.Err() == nil {
+		t.Errorf("expected Send to outlive ctx")
+	}
+
+	if msgs := client.Messages(testQueueURL); len(msgs) != 1 {
+		t.Errorf("expected 1 message sent, got %d", len(msgs))
+	}
+}